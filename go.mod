@@ -3,6 +3,15 @@ module github.com/wksw/protoc-gen-restful2grpc
 go 1.12
 
 require (
+	github.com/andybalholm/brotli v1.0.4
 	github.com/golang/protobuf v1.4.2
+	github.com/klauspost/compress v1.15.9
+	github.com/prometheus/client_golang v0.9.1
+	github.com/stretchr/testify v1.7.0
+	go.opentelemetry.io/contrib/propagators/b3 v1.0.0
+	go.opentelemetry.io/otel v1.0.1
+	go.opentelemetry.io/otel/sdk v1.0.1
+	go.opentelemetry.io/otel/trace v1.0.1
 	google.golang.org/genproto v0.0.0-20201008135153-289734e2e40c
+	google.golang.org/protobuf v1.24.0
 )
@@ -0,0 +1,193 @@
+package restful2grpc
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"gitee.com/paasport/protos-repo/restful"
+	"github.com/golang/protobuf/proto"
+	pb "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	"github.com/wksw/protoc-gen-restful2grpc/generator"
+)
+
+// graphQLWellKnown 作为GraphQL标量的消息, 与restful包中的映射保持一致
+var graphQLWellKnown = map[string]string{
+	".google.protobuf.Timestamp":   "String",
+	".google.protobuf.Duration":    "String",
+	".google.protobuf.FieldMask":   "String",
+	".google.protobuf.StringValue": "String",
+	".google.protobuf.BytesValue":  "String",
+	".google.protobuf.Int64Value":  "String",
+	".google.protobuf.UInt64Value": "String",
+	".google.protobuf.Int32Value":  "Int",
+	".google.protobuf.UInt32Value": "Int",
+	".google.protobuf.DoubleValue": "Float",
+	".google.protobuf.FloatValue":  "Float",
+	".google.protobuf.BoolValue":   "Boolean",
+	".google.protobuf.Struct":      "JSON",
+	".google.protobuf.Value":       "JSON",
+	".google.protobuf.ListValue":   "JSON",
+	".google.protobuf.Any":         "JSON",
+	".google.protobuf.Empty":       "JSON",
+}
+
+// graphQLOperation GET路由作为查询, 修改类的路由作为变更, 流式及HttpBody方法不生成
+func graphQLOperation(method *pb.MethodDescriptorProto) string {
+	if method.GetClientStreaming() || method.GetServerStreaming() ||
+		method.GetInputType() == httpBodyTypeName || method.GetOutputType() == httpBodyTypeName {
+		return ""
+	}
+	ext, err := proto.GetExtension(method.Options, restful.E_Http)
+	if err != nil {
+		return ""
+	}
+	switch ext.(*restful.HttpRule).GetPattern().(type) {
+	case *restful.HttpRule_Get:
+		return "rf.GraphQLQuery"
+	case *restful.HttpRule_Post, *restful.HttpRule_Put, *restful.HttpRule_Patch, *restful.HttpRule_Delete:
+		return "rf.GraphQLMutation"
+	}
+	return ""
+}
+
+// graphQLFieldName 方法对应的字段名, 首字母小写
+func graphQLFieldName(methName string) string {
+	return strings.ToLower(methName[:1]) + methName[1:]
+}
+
+// generateGraphQL 生成<Service>HttpHandler的GraphQL字段及类型, 字段通过方法的rpc路由解析
+func (g *restful2grpc) generateGraphQL(servName, fullServName string, methods []*pb.MethodDescriptorProto) {
+	servHttpAlias := servName + "HttpHandler"
+	types := make(map[string]string)
+	var fields []string
+	for _, method := range methods {
+		op := graphQLOperation(method)
+		if op == "" {
+			continue
+		}
+		methName := generator.CamelCase(method.GetName())
+		name := graphQLFieldName(methName)
+		def := name
+		if args := g.graphQLArguments(method.GetInputType(), types); args != "" {
+			def += "(" + args + ")"
+		}
+		def += ": " + g.graphQLType(method.GetOutputType(), false, types)
+		rpcMethod := "/" + fullServName + "/" + method.GetName()
+		fields = append(fields, "{Operation: "+op+", Name: "+strconv.Quote(name)+", Definition: "+strconv.Quote(def)+", RPCMethod: "+strconv.Quote(rpcMethod)+"},")
+	}
+	names := make([]string, 0, len(types))
+	for name := range types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	g.P()
+	g.P("// GraphQLSchema returns the graphql fields resolved through the rpc routes")
+	g.P("func (h *", servHttpAlias, ") GraphQLSchema() rf.GraphQLSchema {")
+	g.P("return rf.GraphQLSchema{")
+	g.P("Types: map[string]string{")
+	for _, name := range names {
+		g.P(strconv.Quote(name), ": ", strconv.Quote(types[name]), ",")
+	}
+	g.P("},")
+	g.P("Fields: []rf.GraphQLField{")
+	for _, field := range fields {
+		g.P(field)
+	}
+	g.P("},")
+	g.P("}")
+	g.P("}")
+}
+
+// graphQLArguments 请求消息的字段作为参数
+func (g *restful2grpc) graphQLArguments(typeName string, types map[string]string) string {
+	desc, ok := g.gen.ObjectNamed(typeName).(*generator.Descriptor)
+	if !ok || graphQLWellKnown[typeName] != "" {
+		return ""
+	}
+	var args []string
+	for _, field := range desc.Field {
+		args = append(args, field.GetName()+": "+g.graphQLFieldType(field, true, types))
+	}
+	return strings.Join(args, ", ")
+}
+
+// graphQLFieldType 字段的GraphQL类型, 64位整数与json编码一致使用String
+func (g *restful2grpc) graphQLFieldType(field *pb.FieldDescriptorProto, input bool, types map[string]string) string {
+	var t string
+	switch field.GetType() {
+	case pb.FieldDescriptorProto_TYPE_INT32, pb.FieldDescriptorProto_TYPE_SINT32, pb.FieldDescriptorProto_TYPE_SFIXED32,
+		pb.FieldDescriptorProto_TYPE_UINT32, pb.FieldDescriptorProto_TYPE_FIXED32:
+		t = "Int"
+	case pb.FieldDescriptorProto_TYPE_FLOAT, pb.FieldDescriptorProto_TYPE_DOUBLE:
+		t = "Float"
+	case pb.FieldDescriptorProto_TYPE_BOOL:
+		t = "Boolean"
+	case pb.FieldDescriptorProto_TYPE_ENUM:
+		t = g.graphQLEnum(field.GetTypeName(), types)
+	case pb.FieldDescriptorProto_TYPE_MESSAGE, pb.FieldDescriptorProto_TYPE_GROUP:
+		desc, ok := g.gen.ObjectNamed(field.GetTypeName()).(*generator.Descriptor)
+		if ok && desc.GetOptions().GetMapEntry() {
+			// map作为JSON
+			return "JSON"
+		}
+		t = g.graphQLType(field.GetTypeName(), input, types)
+	default:
+		t = "String"
+	}
+	if field.GetLabel() == pb.FieldDescriptorProto_LABEL_REPEATED {
+		return "[" + t + "]"
+	}
+	return t
+}
+
+// graphQLType 消息的GraphQL类型, 并记录类型定义, 作为参数时使用input类型
+func (g *restful2grpc) graphQLType(typeName string, input bool, types map[string]string) string {
+	if t := graphQLWellKnown[typeName]; t != "" {
+		return t
+	}
+	desc, ok := g.gen.ObjectNamed(typeName).(*generator.Descriptor)
+	if !ok || len(desc.Field) == 0 {
+		return "JSON"
+	}
+	name := strings.Join(desc.TypeName(), "_")
+	kind := "type"
+	if input {
+		name += "Input"
+		kind = "input"
+	}
+	if _, ok := types[name]; ok {
+		return name
+	}
+	// 先占位, 避免递归引用的消息重复生成
+	types[name] = ""
+	var b strings.Builder
+	b.WriteString(kind + " " + name + " {\n")
+	for _, field := range desc.Field {
+		b.WriteString("  " + field.GetName() + ": " + g.graphQLFieldType(field, input, types) + "\n")
+	}
+	b.WriteString("}")
+	types[name] = b.String()
+	return name
+}
+
+// graphQLEnum 枚举类型, 值与json编码一致使用枚举名
+func (g *restful2grpc) graphQLEnum(typeName string, types map[string]string) string {
+	enum, ok := g.gen.ObjectNamed(typeName).(*generator.EnumDescriptor)
+	if !ok {
+		return "String"
+	}
+	name := strings.Join(enum.TypeName(), "_")
+	if _, ok := types[name]; ok {
+		return name
+	}
+	var b strings.Builder
+	b.WriteString("enum " + name + " {\n")
+	for _, value := range enum.Value {
+		b.WriteString("  " + value.GetName() + "\n")
+	}
+	b.WriteString("}")
+	types[name] = b.String()
+	return name
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/golang/protobuf/proto"
 	pb "github.com/golang/protobuf/protoc-gen-go/descriptor"
 	"github.com/wksw/protoc-gen-restful2grpc/generator"
+	"google.golang.org/protobuf/encoding/protowire"
 )
 
 // Paths for packages used by code generated in this file,
@@ -25,6 +26,28 @@ const (
 	httpPkgPath    = "net/http"
 )
 
+// 路由元数据中的约定字段, 与restful包中的定义保持一致
+const (
+	redactFieldsMetadata = "redact_fields"
+	uploadFieldsMetadata = "upload_fields"
+	rpcMethodMetadata    = "rpc_method"
+)
+
+// fieldsQueryParam 响应字段掩码的查询参数, 与restful包中的定义保持一致
+const fieldsQueryParam = "fields"
+
+// FieldOptions中的选项字段号
+const (
+	debugRedactField = 16    // debug_redact
+	uploadField      = 50401 // restful2grpc.upload
+)
+
+// uploadTypeName 接收上传文件的消息
+const uploadTypeName = ".restful2grpc.Upload"
+
+// httpBodyTypeName 作为原始内容读取及响应的消息
+const httpBodyTypeName = ".google.api.HttpBody"
+
 func init() {
 	generator.RegisterPlugin(new(restful2grpc))
 }
@@ -139,6 +162,11 @@ func (g *restful2grpc) generateService(file *generator.FileDescriptor, service *
 	if pkg := file.GetPackage(); pkg != "" {
 		serviceName = pkg
 	}
+	// gRPC方法全名中的服务名
+	fullServName := origServName
+	if pkg := file.GetPackage(); pkg != "" {
+		fullServName = pkg + "." + origServName
+	}
 	servName := generator.CamelCase(origServName)
 	servAlias := servName + "Server"
 	servHttpAlias := servName + "HttpHandler"
@@ -151,6 +179,7 @@ func (g *restful2grpc) generateService(file *generator.FileDescriptor, service *
 	// Client method implementations.
 	// http转grpc协议实现
 	var routes []string
+	var routeMethods []*pb.MethodDescriptorProto
 	for _, method := range service.Method {
 		var descExpr string
 		if !method.GetServerStreaming() {
@@ -162,8 +191,9 @@ func (g *restful2grpc) generateService(file *generator.FileDescriptor, service *
 			descExpr = fmt.Sprintf("&%s.Streams[%d]", serviceDescVar, streamIndex)
 			streamIndex++
 		}
-		if route := g.generateClientMethod(serviceName, servName, serviceDescVar, method, descExpr); route != "" {
+		if route := g.generateClientMethod(serviceName, servName, fullServName, serviceDescVar, method, descExpr); route != "" {
 			routes = append(routes, route)
+			routeMethods = append(routeMethods, method)
 		}
 
 	}
@@ -175,6 +205,44 @@ func (g *restful2grpc) generateService(file *generator.FileDescriptor, service *
 	}
 	g.P("return routes")
 	g.P("}")
+	g.generateGraphQL(servName, fullServName, routeMethods)
+	g.generateProxy(servName, routeMethods)
+}
+
+// generateProxy 生成通过<Service>Client调用远程gRPC服务的http转grpc实现, 路由与<Service>HttpHandler相同
+func (g *restful2grpc) generateProxy(servName string, methods []*pb.MethodDescriptorProto) {
+	servHttpAlias := servName + "HttpHandler"
+	proxyAlias := servName + "HttpProxyHandler"
+	g.P()
+	g.P("// ", proxyAlias, " calls the remote service through ", servName, "Client, mapped headers are forwarded as outgoing metadata")
+	g.P("type ", proxyAlias, " struct {")
+	g.P("GrpcClient ", servName, "Client")
+	g.P("}")
+	g.P("func (h *", proxyAlias, ") URLPatterns() []rf.Route {")
+	g.P("return new(", servHttpAlias, ").URLPatterns()")
+	g.P("}")
+	for _, method := range methods {
+		methName := generator.CamelCase(method.GetName())
+		g.P("func (h *", proxyAlias, " )", methName, " (ctx *rf.Context) {")
+		g.P("req, err := new(", servHttpAlias, ").get", methName, "Req(ctx)")
+		g.P("if err != nil {")
+		g.P("rf.Response(ctx, nil, err)")
+		g.P("return")
+		g.P("}")
+		switch {
+		case method.GetServerStreaming() && !method.GetClientStreaming() && method.GetOutputType() == httpBodyTypeName:
+			g.P("stream, err := h.GrpcClient.", methName, "(rf.OutgoingContext(ctx), req)")
+			g.P("rf.ProxyHttpBodyStream(ctx, stream, err)")
+		case method.GetOutputType() == httpBodyTypeName:
+			g.P("resp, err := h.GrpcClient.", methName, "(rf.OutgoingContext(ctx), req)")
+			g.P("rf.ResponseHttpBody(ctx, resp, err)")
+		default:
+			g.P("resp, err := h.GrpcClient.", methName, "(rf.OutgoingContext(ctx), req)")
+			g.P("rf.Response(ctx, resp, err)")
+		}
+		g.P("return")
+		g.P("}")
+	}
 }
 
 // generateClientSignature returns the client-side signature for a method.
@@ -182,14 +250,19 @@ func (g *restful2grpc) generateClientSignature(servName string, method *pb.Metho
 	return ""
 }
 
-func (g *restful2grpc) generateClientMethod(reqServ, servName, serviceDescVar string, method *pb.MethodDescriptorProto, descExpr string) string {
+func (g *restful2grpc) generateClientMethod(reqServ, servName, fullServName, serviceDescVar string, method *pb.MethodDescriptorProto, descExpr string) string {
 	methName := generator.CamelCase(method.GetName())
 	inType := g.typeName(method.GetInputType())
 	servAlias := servName + "HttpHandler"
 
 	g.P("func (h *", servAlias, ")get", methName, "Req(ctx *rf.Context)", "(*", inType, ",error){")
 	g.P("var req ", inType)
-	g.P("err := ctx.Read(&req)")
+	if method.GetInputType() == httpBodyTypeName {
+		// HttpBody请求读取原始请求体
+		g.P("err := ctx.ReadHttpBody(&req)")
+	} else {
+		g.P("err := ctx.Read(&req)")
+	}
 	g.P("return &req, err")
 	g.P("}")
 
@@ -231,13 +304,35 @@ func (g *restful2grpc) generateClientMethod(reqServ, servName, serviceDescVar st
 					}
 				}
 			}
+			// proto中标记了debug_redact的字段在日志中脱敏
+			if fields := g.redactFields(method); len(fields) > 0 {
+				if metadata[redactFieldsMetadata] != "" {
+					fields = append([]string{metadata[redactFieldsMetadata]}, fields...)
+				}
+				metadata[redactFieldsMetadata] = strings.Join(fields, ",")
+			}
+			// 标记了upload选项或Upload类型的字段接收multipart/form-data上传的文件
+			if fields := g.uploadFields(method); len(fields) > 0 {
+				metadata[uploadFieldsMetadata] = strings.Join(fields, ",")
+			}
+			// gRPC-Web及Connect请求的路由
+			metadata[rpcMethodMetadata] = "/" + fullServName + "/" + method.GetName()
 			metadataByte, _ := json.Marshal(metadata)
+			// HttpBody以外的响应支持fields查询参数, 请求消息中有fields字段时只支持$fields
+			var parameters string
+			if method.GetOutputType() != httpBodyTypeName {
+				parameters = "Parameters: rf.FieldMaskParameters(),"
+				if g.hasField(method.GetInputType(), fieldsQueryParam) {
+					parameters = "Parameters: rf.FieldMaskAltParameters(),"
+				}
+			}
 			g.P("return rf.Route{",
 				"Method: ", reqMethod, ",",
 				`Path: "`, path, `",`,
 				`FuncDesc: "`, httpRule.Doc, `",`,
 				`ResourceFuncName: "`, methName, `",`,
 				`Version: "`, httpRule.Version, `",`,
+				parameters,
 				"Metadata: map[string]string", string(metadataByte), ",",
 				"}")
 			g.P("}")
@@ -248,8 +343,19 @@ func (g *restful2grpc) generateClientMethod(reqServ, servName, serviceDescVar st
 			g.P("rf.Response(ctx, nil, err)")
 			g.P("return")
 			g.P("}")
-			g.P("resp, err := h.GrpcHandler.", methName, "(ctx.Ctx, req)")
-			g.P("rf.Response(ctx, resp, err)")
+			switch {
+			case method.GetServerStreaming() && !method.GetClientStreaming() && method.GetOutputType() == httpBodyTypeName:
+				// 服务端流式返回的HttpBody以分块传输响应
+				g.P("stream := rf.NewHttpBodyStream(ctx)")
+				g.P("stream.Close(h.GrpcHandler.", methName, "(req, stream))")
+			case method.GetOutputType() == httpBodyTypeName:
+				// HttpBody响应直接写入原始内容
+				g.P("resp, err := h.GrpcHandler.", methName, "(ctx.Ctx, req)")
+				g.P("rf.ResponseHttpBody(ctx, resp, err)")
+			default:
+				g.P("resp, err := h.GrpcHandler.", methName, "(ctx.Ctx, req)")
+				g.P("rf.Response(ctx, resp, err)")
+			}
 			g.P("return")
 			g.P("}")
 			routeName = methName
@@ -257,3 +363,92 @@ func (g *restful2grpc) generateClientMethod(reqServ, servName, serviceDescVar st
 	}
 	return routeName
 }
+
+// redactFields 返回请求及响应消息中标记了debug_redact的字段名
+func (g *restful2grpc) redactFields(method *pb.MethodDescriptorProto) []string {
+	var fields []string
+	seen := make(map[string]bool)
+	var walk func(typeName string)
+	walk = func(typeName string) {
+		if seen[typeName] {
+			return
+		}
+		seen[typeName] = true
+		desc, ok := g.gen.ObjectNamed(typeName).(*generator.Descriptor)
+		if !ok {
+			return
+		}
+		for _, field := range desc.Field {
+			if boolOption(field, debugRedactField) {
+				fields = append(fields, field.GetName())
+				continue
+			}
+			if field.GetType() == pb.FieldDescriptorProto_TYPE_MESSAGE {
+				walk(field.GetTypeName())
+			}
+		}
+	}
+	walk(method.GetInputType())
+	walk(method.GetOutputType())
+	return fields
+}
+
+// hasField 消息中是否有指定名称的字段
+func (g *restful2grpc) hasField(typeName, name string) bool {
+	desc, ok := g.gen.ObjectNamed(typeName).(*generator.Descriptor)
+	if !ok {
+		return false
+	}
+	for _, field := range desc.Field {
+		if field.GetName() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// uploadFields 返回请求消息中接收上传文件的字段名
+func (g *restful2grpc) uploadFields(method *pb.MethodDescriptorProto) []string {
+	desc, ok := g.gen.ObjectNamed(method.GetInputType()).(*generator.Descriptor)
+	if !ok {
+		return nil
+	}
+	var fields []string
+	for _, field := range desc.Field {
+		if field.GetTypeName() == uploadTypeName ||
+			(field.GetType() == pb.FieldDescriptorProto_TYPE_BYTES && boolOption(field, uploadField)) {
+			fields = append(fields, field.GetName())
+		}
+	}
+	return fields
+}
+
+// boolOption 字段是否设置了布尔类型的选项
+// 旧版本的descriptor中没有debug_redact选项, 扩展选项也未注册, 需从未识别的字段中解析
+func boolOption(field *pb.FieldDescriptorProto, number protowire.Number) bool {
+	if field.GetOptions() == nil {
+		return false
+	}
+	m := proto.MessageV2(field.GetOptions()).ProtoReflect()
+	if fd := m.Descriptor().Fields().ByNumber(number); fd != nil {
+		return m.Get(fd).Bool()
+	}
+	b := m.GetUnknown()
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+		if num == number && typ == protowire.VarintType {
+			v, n := protowire.ConsumeVarint(b)
+			return n > 0 && v != 0
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return false
+		}
+		b = b[n:]
+	}
+	return false
+}
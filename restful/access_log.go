@@ -0,0 +1,213 @@
+package restful
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-mesh/openlogging"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 访问日志配置
+const (
+	AccessLogEnableKey        = "cse.restful.accessLog.enable"
+	AccessLogRedactHeadersKey = "cse.restful.accessLog.redactHeaders" // 逗号分隔的脱敏头域
+	AccessLogRedactFieldsKey  = "cse.restful.accessLog.redactFields"  // 逗号分隔的脱敏字段
+)
+
+// REDACTED 脱敏后的值
+const REDACTED = "******"
+
+// 请求属性, 当前路由的脱敏规则
+const attrRedactor = "restful.redactor"
+
+// DefaultRedactHeaders 默认脱敏的头域
+var DefaultRedactHeaders = []string{
+	Header_auth,
+	Header_x_auth_token,
+	Header_x_sub_token,
+	"cookie",
+	"set-cookie",
+}
+
+var defaultRedactor = newRedactor(DefaultRedactHeaders, nil)
+
+// redactor 对头域及消息体中的敏感信息脱敏
+type redactor struct {
+	headers map[string]bool
+	fields  map[string]bool
+}
+
+func newRedactor(headers, fields []string) *redactor {
+	r := &redactor{
+		headers: make(map[string]bool),
+		fields:  make(map[string]bool),
+	}
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			r.headers[strings.ToLower(h)] = true
+		}
+	}
+	for _, f := range fields {
+		if f = strings.TrimSpace(f); f != "" {
+			r.fields[f] = true
+		}
+	}
+	return r
+}
+
+// routeRedactor 根据配置及路由元数据生成路由的脱敏规则
+func routeRedactor(route Route) *redactor {
+	headers := append([]string{}, DefaultRedactHeaders...)
+	headers = append(headers, strings.Split(archaius.GetString(AccessLogRedactHeadersKey, ""), ",")...)
+	fields := strings.Split(archaius.GetString(AccessLogRedactFieldsKey, ""), ",")
+	fields = append(fields, strings.Split(route.Metadata[REDACT_FIELDS_METADATA], ",")...)
+	return newRedactor(headers, fields)
+}
+
+// requestRedactor 获取请求对应的脱敏规则
+func requestRedactor(req *restful.Request) *redactor {
+	if req != nil {
+		if r, ok := req.Attribute(attrRedactor).(*redactor); ok {
+			return r
+		}
+	}
+	return defaultRedactor
+}
+
+// header 返回脱敏后的头域
+func (r *redactor) header(header http.Header) map[string]string {
+	out := make(map[string]string, len(header))
+	for k := range header {
+		out[k] = r.headerValue(k, header.Get(k))
+	}
+	return out
+}
+
+func (r *redactor) headerValue(name, value string) string {
+	if value != "" && r.headers[strings.ToLower(name)] {
+		return REDACTED
+	}
+	return value
+}
+
+// body 返回脱敏后消息体的json格式
+func (r *redactor) body(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "<" + err.Error() + ">"
+	}
+	if len(r.fields) == 0 {
+		return string(data)
+	}
+	var obj interface{}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return string(data)
+	}
+	data, _ = json.Marshal(r.redactValue(obj))
+	return string(data)
+}
+
+// lazyHeader 输出时才脱敏的头域, 用于调试日志, 未开启调试时不处理
+func (r *redactor) lazyHeader(header http.Header) fmt.Stringer {
+	return redactedHeader{r: r, header: header}
+}
+
+// lazyBody 输出时才脱敏的消息体, 用于调试日志, 未开启调试时不序列化
+func (r *redactor) lazyBody(v interface{}) fmt.Stringer {
+	return redactedBody{r: r, v: v}
+}
+
+type redactedHeader struct {
+	r      *redactor
+	header http.Header
+}
+
+func (h redactedHeader) String() string {
+	return fmt.Sprint(h.r.header(h.header))
+}
+
+type redactedBody struct {
+	r *redactor
+	v interface{}
+}
+
+func (b redactedBody) String() string {
+	return b.r.body(b.v)
+}
+
+func (r *redactor) redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, field := range val {
+			if r.fields[k] {
+				val[k] = REDACTED
+				continue
+			}
+			val[k] = r.redactValue(field)
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = r.redactValue(val[i])
+		}
+	}
+	return v
+}
+
+// accessLogger 记录单个路由的访问日志
+type accessLogger struct {
+	path     string
+	redactor *redactor
+}
+
+func newAccessLogger(route Route) *accessLogger {
+	return &accessLogger{
+		path:     routeTemplate(route),
+		redactor: routeRedactor(route),
+	}
+}
+
+// begin 请求开始, 返回请求结束时调用的函数
+func (l *accessLogger) begin(req *restful.Request, rep *restful.Response) func() {
+	start := time.Now()
+	req.SetAttribute(attrRedactor, l.redactor)
+	return func() {
+		if !archaius.GetBool(AccessLogEnableKey, false) {
+			return
+		}
+		tags := openlogging.Tags{
+			"method":    req.Request.Method,
+			"path":      l.path,
+			"status":    rep.StatusCode(),
+			"latency":   time.Since(start).String(),
+			"bytes":     rep.ContentLength(),
+			"client_ip": ClientIP(req.Request),
+			"trace_id":  accessTraceId(req, rep),
+		}
+		if errCode, ok := req.Attribute(attrErrCode).(int); ok {
+			tags["err_code"] = strconv.Itoa(errCode)
+		}
+		openlogging.GetLogger().Info("access", openlogging.WithTags(tags))
+		openlogging.GetLogger().Debugf("access headers: %v", l.redactor.lazyHeader(req.Request.Header))
+	}
+}
+
+// accessTraceId 访问日志中的链路ID
+func accessTraceId(req *restful.Request, rep *restful.Response) string {
+	if id := rep.Header().Get(Header_trace); id != "" {
+		return id
+	}
+	if id := req.Request.Header.Get(Header_trace); id != "" {
+		return id
+	}
+	if sc := trace.SpanContextFromContext(req.Request.Context()); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,53 @@
+package restful
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type redactTestUser struct {
+	Name     string `json:"name,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+type redactTestResponse struct {
+	Token string            `json:"token,omitempty"`
+	Users []*redactTestUser `json:"users,omitempty"`
+}
+
+func TestRedactor(t *testing.T) {
+	r := newRedactor(DefaultRedactHeaders, []string{"password", " token "})
+
+	header := http.Header{}
+	header.Set(Header_auth, "Bearer secret")
+	header.Set(Header_app_id, "app")
+	out := r.header(header)
+	assert.Equal(t, REDACTED, out["Authorization"])
+	assert.Equal(t, "app", out["Paasport-App-Id"])
+
+	body := r.body(&redactTestResponse{
+		Token: "secret",
+		Users: []*redactTestUser{{Name: "admin", Password: "admin"}},
+	})
+	assert.JSONEq(t, `{"token":"******","users":[{"name":"admin","password":"******"}]}`, body)
+
+	assert.Equal(t, "null", defaultRedactor.body(nil))
+
+	// 调试日志输出时才脱敏
+	assert.Equal(t, body, r.lazyBody(&redactTestResponse{
+		Token: "secret",
+		Users: []*redactTestUser{{Name: "admin", Password: "admin"}},
+	}).String())
+	assert.Contains(t, r.lazyHeader(header).String(), "Authorization:"+REDACTED)
+}
+
+func TestClientIP(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	assert.Equal(t, "10.0.0.1", ClientIP(req))
+
+	req.Header.Set(Header_x_forwarded_for, "1.1.1.1, 10.0.0.2")
+	assert.Equal(t, "1.1.1.1", ClientIP(req))
+}
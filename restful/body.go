@@ -0,0 +1,83 @@
+package restful
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultBodyBufferLimit 路由开启buffer_body但未指定大小时暂存请求体的上限
+var DefaultBodyBufferLimit int64 = 4 << 20
+
+// ErrBodyTooLarge 请求体超过BodyLimit或暂存上限, 以413响应
+var ErrBodyTooLarge = status.Errorf(codes.ResourceExhausted, "(%d)request body too large", BODY_TOO_LARGE_ERR)
+
+// parseSize 解析字节数, 支持 KB, MB 单位
+func parseSize(v string) (int64, error) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	unit := int64(1)
+	for _, u := range []struct {
+		suffix string
+		size   int64
+	}{{"KB", 1 << 10}, {"MB", 1 << 20}, {"K", 1 << 10}, {"M", 1 << 20}, {"B", 1}} {
+		if strings.HasSuffix(v, u.suffix) {
+			unit = u.size
+			v = strings.TrimSpace(strings.TrimSuffix(v, u.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid size '%s'", v)
+	}
+	return n * unit, nil
+}
+
+// routeBodyBuffer 路由暂存请求体的上限, 未开启时为0
+func routeBodyBuffer(route Route) (int64, error) {
+	v := strings.TrimSpace(route.Metadata[BUFFER_BODY_METADATA])
+	switch strings.ToLower(v) {
+	case "", "false":
+		return 0, nil
+	case "true":
+		return DefaultBodyBufferLimit, nil
+	}
+	return parseSize(v)
+}
+
+// limitedBody 限制请求体大小, 超过时返回ErrBodyTooLarge
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+}
+
+// limitBody 使用BodyLimit限制请求体大小
+func limitBody(req *http.Request, limit int64) {
+	if limit <= 0 || req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+	req.Body = &limitedBody{ReadCloser: req.Body, remaining: limit}
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if b.remaining < 0 {
+		return 0, ErrBodyTooLarge
+	}
+	// 多读一个字节判断是否超过限制
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) <= b.remaining {
+		b.remaining -= int64(n)
+		return n, err
+	}
+	n = int(b.remaining)
+	b.remaining = -1
+	return n, ErrBodyTooLarge
+}
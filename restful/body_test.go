@@ -0,0 +1,94 @@
+package restful
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteBodyBuffer(t *testing.T) {
+	for v, size := range map[string]int64{
+		"":      0,
+		"false": 0,
+		"true":  DefaultBodyBufferLimit,
+		"512":   512,
+		"64KB":  64 << 10,
+		"2m":    2 << 20,
+	} {
+		n, err := routeBodyBuffer(Route{Metadata: map[string]string{BUFFER_BODY_METADATA: v}})
+		assert.NoError(t, err, v)
+		assert.Equal(t, size, n, v)
+	}
+	_, err := routeBodyBuffer(Route{Metadata: map[string]string{BUFFER_BODY_METADATA: "-1"}})
+	assert.Error(t, err)
+}
+
+func TestLimitBody(t *testing.T) {
+	r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+	limitBody(r, 10)
+	data, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Equal(t, "0123456789", string(data))
+
+	r, _ = http.NewRequest(http.MethodPost, "/", strings.NewReader("0123456789"))
+	limitBody(r, 9)
+	data, err = ioutil.ReadAll(r.Body)
+	assert.Equal(t, ErrBodyTooLarge, err)
+	assert.Len(t, data, 9)
+}
+
+func TestReadEntityBody(t *testing.T) {
+	type entity struct {
+		Name string `json:"name"`
+	}
+	newBodyContext := func(body string, bodyLimit, bodyBuffer int64) *Context {
+		r, _ := http.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		r.Header.Set(Header_content_type, restful.MIME_JSON)
+		limitBody(r, bodyLimit)
+		bs := NewBaseServer(context.TODO())
+		bs.Req = restful.NewRequest(r)
+		bs.Resp = restful.NewResponse(httptest.NewRecorder())
+		bs.bodyBuffer = bodyBuffer
+		return bs
+	}
+	body := `{"name":"restful2grpc"}`
+
+	// 未开启暂存时直接解码
+	bs := newBodyContext(body, 0, 0)
+	var e entity
+	assert.NoError(t, bs.ReadEntity(&e))
+	assert.Equal(t, "restful2grpc", e.Name)
+	assert.Nil(t, bs.ReqBody)
+
+	bs = newBodyContext(body, 0, 1024)
+	e = entity{}
+	assert.NoError(t, bs.ReadEntity(&e))
+	assert.Equal(t, "restful2grpc", e.Name)
+	assert.Equal(t, body, string(bs.ReqBody))
+
+	bs = newBodyContext(body, 0, 8)
+	assert.Equal(t, ErrBodyTooLarge, bs.ReadEntity(&e))
+
+	bs = newBodyContext(body, 8, 0)
+	assert.Equal(t, ErrBodyTooLarge, bs.ReadEntity(&e))
+}
+
+func TestBodyTooLargeResponse(t *testing.T) {
+	initLogger()
+	r, _ := http.NewRequest(http.MethodPost, "/", nil)
+	rw := httptest.NewRecorder()
+	Response(newContext(restful.NewRequest(r), restful.NewResponse(rw)), nil, ErrBodyTooLarge)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+	assert.Contains(t, rw.Body.String(), "10415")
+
+	r, _ = http.NewRequest(http.MethodPost, "/?ihc=1", nil)
+	rw = httptest.NewRecorder()
+	Response(newContext(restful.NewRequest(r), restful.NewResponse(rw)), nil, ErrBodyTooLarge)
+	assert.Equal(t, http.StatusOK, rw.Code)
+}
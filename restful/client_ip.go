@@ -0,0 +1,86 @@
+package restful
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-mesh/openlogging"
+)
+
+// 可信代理配置, 逗号分隔的IP或网段, 配置变化时立即生效
+// 直连地址为可信代理时才使用x-forwarded-for, 维护模式及限流使用解析出的地址
+const TrustedProxiesKey = "cse.restful.trustedProxies"
+
+// trustedProxies 可信代理
+type trustedProxies struct {
+	mu   sync.RWMutex
+	nets []*net.IPNet
+}
+
+// defaultTrustedProxies 维护模式及限流使用的可信代理, 默认为空, 只使用直连地址
+var defaultTrustedProxies = &trustedProxies{}
+
+// Event 配置变化时重新加载可信代理
+func (t *trustedProxies) Event(e *event.Event) {
+	openlogging.GetLogger().Infof("trusted proxies config '%s' changed, reload", e.Key)
+	t.load()
+}
+
+// load 从配置中加载可信代理
+func (t *trustedProxies) load() {
+	var nets []*net.IPNet
+	for _, ip := range splitList(archaius.GetString(TrustedProxiesKey, "")) {
+		if ipNet := parseIPNet(ip); ipNet != nil {
+			nets = append(nets, ipNet)
+		} else {
+			openlogging.GetLogger().Warnf("invalid trusted proxy '%s'", ip)
+		}
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nets = nets
+}
+
+// clientIP 获取可信的客户端IP
+// 直连地址不是可信代理时直接使用, 否则从右向左取x-forwarded-for中第一个不是可信代理的地址
+func (t *trustedProxies) clientIP(req *http.Request) string {
+	client := req.RemoteAddr
+	if host, _, err := net.SplitHostPort(client); err == nil {
+		client = host
+	}
+	t.mu.RLock()
+	nets := t.nets
+	t.mu.RUnlock()
+	if !ipInNets(net.ParseIP(client), nets) {
+		return client
+	}
+	hops := strings.Split(req.Header.Get(Header_x_forwarded_for), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		client = hop
+		if !ipInNets(net.ParseIP(hop), nets) {
+			break
+		}
+	}
+	return client
+}
+
+// ipInNets IP是否在任一网段中
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
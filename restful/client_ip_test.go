@@ -0,0 +1,33 @@
+package restful
+
+import (
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrustedClientIP(t *testing.T) {
+	proxies := &trustedProxies{}
+	call := func(remoteAddr, forwarded string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = remoteAddr
+		if forwarded != "" {
+			req.Header.Set(Header_x_forwarded_for, forwarded)
+		}
+		return proxies.clientIP(req)
+	}
+
+	// 未配置可信代理时只使用直连地址
+	assert.Equal(t, "1.2.3.4", call("1.2.3.4:80", "10.0.0.1"))
+	assert.Equal(t, "1.2.3.4", call("1.2.3.4", ""))
+
+	proxies.nets = []*net.IPNet{parseIPNet("172.16.0.0/12"), parseIPNet("192.168.1.1")}
+	assert.Equal(t, "1.2.3.4", call("1.2.3.4:80", "10.0.0.1"))
+	assert.Equal(t, "10.0.0.1", call("172.16.0.1:80", "10.0.0.1"))
+	// 从右向左跳过可信代理, 左侧由客户端写入的地址不使用
+	assert.Equal(t, "5.6.7.8", call("172.16.0.1:80", "10.0.0.1, 5.6.7.8, 192.168.1.1"))
+	assert.Equal(t, "192.168.1.1", call("172.16.0.1:80", " , 192.168.1.1"))
+	assert.Equal(t, "172.16.0.1", call("172.16.0.1:80", ""))
+}
@@ -0,0 +1,298 @@
+package restful
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
+	"github.com/klauspost/compress/zstd"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 响应压缩配置
+const (
+	CompressionConfigPrefix   = "cse.restful.compression"
+	CompressionEnableKey      = CompressionConfigPrefix + ".enable"
+	CompressionMinSizeKey     = CompressionConfigPrefix + ".minSize"   // 压缩的最小字节数
+	CompressionTypesKey       = CompressionConfigPrefix + ".types"     // 逗号分隔的可压缩内容类型, 支持 text/*
+	CompressionEncodingsKey   = CompressionConfigPrefix + ".encodings" // 逗号分隔的压缩算法, 按优先级排列
+	defaultCompressionMinSize = 1024
+)
+
+// 支持的压缩算法
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+)
+
+// DefaultCompressionTypes 默认可压缩的内容类型
+var DefaultCompressionTypes = []string{
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"text/*",
+}
+
+// DefaultCompressionEncodings 默认的压缩算法及优先级
+var DefaultCompressionEncodings = []string{EncodingBrotli, EncodingZstd, EncodingGzip}
+
+// encoder 压缩写入器
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// 压缩写入器的初始化开销较大, 复用
+var encoderPools = map[string]*sync.Pool{
+	EncodingGzip: {New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	EncodingBrotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, brotli.DefaultCompression)
+	}},
+	EncodingZstd: {New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+		return w
+	}},
+}
+
+// compressor 根据Accept-Encoding压缩响应
+type compressor struct {
+	minSize   int
+	types     []string
+	encodings []string
+}
+
+// newCompressor 根据配置生成响应压缩, 未开启时返回nil
+func newCompressor() *compressor {
+	if !archaius.GetBool(CompressionEnableKey, false) {
+		return nil
+	}
+	c := &compressor{
+		minSize:   archaius.GetInt(CompressionMinSizeKey, defaultCompressionMinSize),
+		types:     splitList(archaius.GetString(CompressionTypesKey, "")),
+		encodings: splitList(archaius.GetString(CompressionEncodingsKey, "")),
+	}
+	if len(c.types) == 0 {
+		c.types = DefaultCompressionTypes
+	}
+	if len(c.encodings) == 0 {
+		c.encodings = DefaultCompressionEncodings
+	}
+	return c
+}
+
+// negotiate 选择客户端可接受的压缩算法, q值相同时按服务端优先级, 没有可用算法时为空
+func (c *compressor) negotiate(acceptEncoding string) string {
+	accepted := make(map[string]float64)
+	for _, item := range strings.Split(acceptEncoding, ",") {
+		parts := strings.Split(item, ";")
+		name := strings.ToLower(strings.TrimSpace(parts[0]))
+		if name == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range parts[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		accepted[name] = q
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range c.encodings {
+		if _, ok := encoderPools[encoding]; !ok {
+			continue
+		}
+		q, ok := accepted[encoding]
+		if !ok {
+			q = accepted["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressible 内容类型是否可压缩
+func (c *compressor) compressible(contentType string) bool {
+	contentType = strings.ToLower(strings.TrimSpace(strings.Split(contentType, ";")[0]))
+	if contentType == "" {
+		return false
+	}
+	for _, t := range c.types {
+		if t == contentType || (strings.HasSuffix(t, "/*") && strings.HasPrefix(contentType, t[:len(t)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// wrap 替换响应的写入器, 客户端不接受压缩时返回nil
+func (c *compressor) wrap(req *restful.Request, rep *restful.Response) *compressWriter {
+	if c == nil || req.Request.Method == http.MethodHead {
+		return nil
+	}
+	encoding := c.negotiate(req.Request.Header.Get(Header_accept_encoding))
+	if encoding == "" {
+		return nil
+	}
+	w := &compressWriter{ResponseWriter: rep.ResponseWriter, compressor: c, encoding: encoding}
+	rep.ResponseWriter = w
+	return w
+}
+
+// compressWriter 缓存响应直到达到压缩的最小字节数, 再决定是否压缩
+type compressWriter struct {
+	http.ResponseWriter
+	compressor *compressor
+	encoding   string
+	status     int
+	buf        []byte
+	decided    bool
+	encoder    encoder // 压缩时不为nil
+}
+
+// WriteHeader 延迟到决定是否压缩后写入
+func (w *compressWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.compressor.minSize {
+			return len(b), nil
+		}
+		if err := w.decide(); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// decide 决定是否压缩, 写入头域及缓存的响应
+func (w *compressWriter) decide() error {
+	w.decided = true
+	header := w.Header()
+	if w.compressor.compressible(header.Get(Header_content_type)) {
+		header.Add(Header_vary, "Accept-Encoding")
+		if len(w.buf) >= w.compressor.minSize && header.Get(Header_content_encoding) == "" &&
+			w.status != http.StatusNoContent && w.status != http.StatusNotModified && w.status != http.StatusPartialContent {
+			header.Del(Header_content_length)
+			header.Set(Header_content_encoding, w.encoding)
+			w.encoder = encoderPools[w.encoding].Get().(encoder)
+			w.encoder.Reset(w.ResponseWriter)
+		}
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.encoder != nil {
+		_, err = w.encoder.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// Flush implements http.Flusher
+func (w *compressWriter) Flush() {
+	if !w.decided && w.status != 0 {
+		w.decide()
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// CloseNotify implements http.CloseNotifier
+func (w *compressWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Close 写入剩余的响应并结束压缩
+func (w *compressWriter) Close() error {
+	if !w.decided {
+		if w.status == 0 {
+			return nil
+		}
+		if err := w.decide(); err != nil {
+			return err
+		}
+	}
+	if w.encoder == nil {
+		return nil
+	}
+	err := w.encoder.Close()
+	w.encoder.Reset(nil)
+	encoderPools[w.encoding].Put(w.encoder)
+	w.encoder = nil
+	return err
+}
+
+// gzipBody 解压后的请求体, 关闭时同时关闭原请求体
+type gzipBody struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (b gzipBody) Close() error {
+	b.Reader.Close()
+	return b.body.Close()
+}
+
+// decompressRequest 解压gzip请求体
+// 解压在BodyLimit之前进行, 请求体大小按解压后计算
+func decompressRequest(req *http.Request) error {
+	encoding := strings.ToLower(strings.TrimSpace(req.Header.Get(Header_content_encoding)))
+	switch encoding {
+	case "", "identity":
+		return nil
+	case EncodingGzip:
+	default:
+		return status.Errorf(codes.InvalidArgument, "(%d)unsupported content encoding '%s'", INVALID_CONTENT_ENCODING_ERR, encoding)
+	}
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil
+	}
+	zr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "(%d)invalid gzip request body: %s", INVALID_CONTENT_ENCODING_ERR, err.Error())
+	}
+	req.Body = gzipBody{Reader: zr, body: req.Body}
+	req.Header.Del(Header_content_encoding)
+	req.Header.Del(Header_content_length)
+	req.ContentLength = -1
+	return nil
+}
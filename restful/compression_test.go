@@ -0,0 +1,119 @@
+package restful
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/emicklei/go-restful"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressorNegotiate(t *testing.T) {
+	c := &compressor{encodings: DefaultCompressionEncodings}
+	assert.Equal(t, EncodingBrotli, c.negotiate("gzip, deflate, br"))
+	assert.Equal(t, EncodingGzip, c.negotiate("gzip;q=1.0, br;q=0.5"))
+	assert.Equal(t, EncodingZstd, c.negotiate("zstd, gzip"))
+	assert.Equal(t, EncodingZstd, c.negotiate("br;q=0, *"))
+	assert.Equal(t, "", c.negotiate("deflate"))
+	assert.Equal(t, "", c.negotiate(""))
+}
+
+func TestCompressWriter(t *testing.T) {
+	c := &compressor{minSize: 64, types: DefaultCompressionTypes, encodings: DefaultCompressionEncodings}
+	large := strings.Repeat(`{"name":"restful2grpc"}`, 100)
+	decode := map[string]func([]byte) string{
+		EncodingGzip: func(b []byte) string {
+			r, err := gzip.NewReader(bytes.NewReader(b))
+			assert.NoError(t, err)
+			data, _ := ioutil.ReadAll(r)
+			return string(data)
+		},
+		EncodingBrotli: func(b []byte) string {
+			data, _ := ioutil.ReadAll(brotli.NewReader(bytes.NewReader(b)))
+			return string(data)
+		},
+		EncodingZstd: func(b []byte) string {
+			r, err := zstd.NewReader(bytes.NewReader(b))
+			assert.NoError(t, err)
+			defer r.Close()
+			data, _ := ioutil.ReadAll(r)
+			return string(data)
+		},
+	}
+	call := func(acceptEncoding, contentType, body string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodGet, "/list", nil)
+		r.Header.Set(Header_accept_encoding, acceptEncoding)
+		rw := httptest.NewRecorder()
+		rep := restful.NewResponse(rw)
+		w := c.wrap(restful.NewRequest(r), rep)
+		rep.AddHeader(Header_content_type, contentType)
+		rep.WriteHeader(http.StatusCreated)
+		rep.Write([]byte(body))
+		if w != nil {
+			assert.NoError(t, w.Close())
+		}
+		return rw
+	}
+
+	for encoding, fn := range decode {
+		rw := call(encoding, "application/json;charset=utf-8", large)
+		assert.Equal(t, http.StatusCreated, rw.Code)
+		assert.Equal(t, encoding, rw.Header().Get(Header_content_encoding))
+		assert.Equal(t, "Accept-Encoding", rw.Header().Get(Header_vary))
+		assert.True(t, rw.Body.Len() < len(large))
+		assert.Equal(t, large, fn(rw.Body.Bytes()))
+	}
+
+	rw := call(EncodingGzip, "application/json", `{"small":true}`)
+	assert.Equal(t, http.StatusCreated, rw.Code)
+	assert.Empty(t, rw.Header().Get(Header_content_encoding))
+	assert.Equal(t, `{"small":true}`, rw.Body.String())
+
+	rw = call(EncodingGzip, "image/png", large)
+	assert.Empty(t, rw.Header().Get(Header_content_encoding))
+	assert.Equal(t, large, rw.Body.String())
+
+	rw = call("identity", "application/json", large)
+	assert.Empty(t, rw.Header().Get(Header_content_encoding))
+	assert.Equal(t, large, rw.Body.String())
+}
+
+func TestDecompressRequest(t *testing.T) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write(bytes.Repeat([]byte("a"), 4096))
+	zw.Close()
+
+	newRequest := func(encoding string, body []byte) *http.Request {
+		r, _ := http.NewRequest(http.MethodPost, "/upload", bytes.NewReader(body))
+		r.Header.Set(Header_content_encoding, encoding)
+		return r
+	}
+
+	r := newRequest(EncodingGzip, buf.Bytes())
+	assert.NoError(t, decompressRequest(r))
+	assert.Empty(t, r.Header.Get(Header_content_encoding))
+	data, err := ioutil.ReadAll(r.Body)
+	assert.NoError(t, err)
+	assert.Len(t, data, 4096)
+
+	// BodyLimit作用于解压后的大小
+	r = newRequest(EncodingGzip, buf.Bytes())
+	assert.NoError(t, decompressRequest(r))
+	r.Body = http.MaxBytesReader(httptest.NewRecorder(), r.Body, 1024)
+	_, err = ioutil.ReadAll(r.Body)
+	assert.Error(t, err)
+
+	_, errCode, _ := ParseError(decompressRequest(newRequest(EncodingGzip, []byte("plain"))))
+	assert.Equal(t, INVALID_CONTENT_ENCODING_ERR, errCode)
+	_, errCode, _ = ParseError(decompressRequest(newRequest(EncodingBrotli, buf.Bytes())))
+	assert.Equal(t, INVALID_CONTENT_ENCODING_ERR, errCode)
+	assert.NoError(t, decompressRequest(newRequest("", []byte("plain"))))
+}
@@ -8,4 +8,24 @@ const (
 	BODY_INONEBOX_PARAM    = "onebox"
 	IGNORE_HTTP_CODE_PARAM = "ihc"
 	SING_NONCE_PARAM       = "sign_nonce" // 签名随机值
+	FIELDS_QUERY_PARAM     = "fields"     // 响应字段掩码, 逗号分隔, 如 a,b.c
+	FIELDS_QUERY_PARAM_ALT = "$fields"    // 与fields相同, 请求消息中有fields字段时只能使用$fields
+)
+
+// 路由元数据中的约定字段
+const (
+	TIMEOUT_METADATA     = "timeout"     // 路由默认超时时间, 如 5s
+	BUFFER_BODY_METADATA = "buffer_body" // 是否暂存请求体供签名校验或审计使用, true 或暂存上限如 1MB
+
+	REDACT_FIELDS_METADATA = "redact_fields" // 逗号分隔的需要脱敏的字段, 插件会将proto中标记了debug_redact的字段自动写入
+
+	UPLOAD_FIELDS_METADATA        = "upload_fields"        // 逗号分隔的接收上传文件的字段, 由插件写入
+	UPLOAD_MAX_PART_SIZE_METADATA = "upload_max_part_size" // 单个表单项的大小上限, 如 10MB
+	UPLOAD_MAX_PARTS_METADATA     = "upload_max_parts"     // 表单项的数量上限
+	UPLOAD_TEMP_METADATA          = "upload_temp"          // 为true时文件写入临时文件, 由Upload.path读取
+
+	ETAG_METADATA       = "etag"       // 为false时GET路由不计算ETag
+	ETAG_FIELD_METADATA = "etag_field" // 作为ETag的响应字段, 为空时根据响应内容计算
+
+	RPC_METHOD_METADATA = "rpc_method" // gRPC方法全名, 如 /greeter.Greeter/Hello, 由插件写入, 用于gRPC-Web及Connect路由
 )
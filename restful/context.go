@@ -3,6 +3,7 @@ package restful
 import (
 	"bytes"
 	"context"
+	"io"
 	"io/ioutil"
 	"net/http"
 
@@ -15,7 +16,20 @@ type Context struct {
 	Req  *restful.Request
 	Resp *restful.Response
 	// 将request body暂存在这，后续二次读取
+	// 仅在路由开启buffer_body时暂存
 	ReqBody []byte
+	// 暂存请求体的上限, 为0时不暂存
+	bodyBuffer int64
+	// 文件上传规则
+	upload *uploadSpec
+	// 上传文件的临时文件, 请求结束后删除
+	tempFiles []string
+	// GET路由的ETag规则
+	etag *etagSpec
+	// 请求消息中有fields字段, 只读取$fields作为字段掩码
+	fieldsReserved bool
+	// gRPC-Web或Connect请求的协议, 普通http请求为nil
+	rpc *rpcProtocol
 }
 
 //NewBaseServer is a function which return context
@@ -25,6 +39,16 @@ func NewBaseServer(ctx context.Context) *Context {
 	}
 }
 
+// newContext 使用请求的上下文创建Context
+func newContext(req *restful.Request, rep *restful.Response) *Context {
+	return &Context{
+		Ctx:  req.Request.Context(),
+		Req:  req,
+		Resp: rep,
+		rpc:  rpcProtocolFrom(req.Request.Context()),
+	}
+}
+
 // write is the response writer.
 func (bs *Context) Write(body []byte) {
 	bs.Resp.Write(body)
@@ -56,10 +80,16 @@ func (bs *Context) WriteHeaderAndJSON(status int, value interface{}, contentType
 }
 
 //ReadEntity is request reader
+// 路由开启buffer_body时先暂存请求体, 否则直接从请求体流式解码
 func (bs *Context) ReadEntity(schema interface{}) (err error) {
-	// 将请求体暂存起来
-	bs.ReqBody, _ = ioutil.ReadAll(bs.Req.Request.Body)
-	bs.Req.Request.Body = ioutil.NopCloser(bytes.NewBuffer(bs.ReqBody))
+	if bs.Req.Request.Body == nil {
+		bs.Req.Request.Body = http.NoBody
+	}
+	if bs.bodyBuffer > 0 {
+		if err := bs.bufferBody(); err != nil {
+			return err
+		}
+	}
 	// 这里会读取body体参数
 	if err := bs.Req.ReadEntity(schema); err != nil {
 		return err
@@ -67,12 +97,35 @@ func (bs *Context) ReadEntity(schema interface{}) (err error) {
 	return bs.ReadQueryEntity(schema)
 }
 
+// bufferBody 将请求体暂存起来, 超过暂存上限时返回ErrBodyTooLarge
+func (bs *Context) bufferBody() error {
+	if bs.ReqBody == nil {
+		body, err := ioutil.ReadAll(io.LimitReader(bs.Req.Request.Body, bs.bodyBuffer+1))
+		if err != nil {
+			return err
+		}
+		if int64(len(body)) > bs.bodyBuffer {
+			return ErrBodyTooLarge
+		}
+		bs.ReqBody = body
+	}
+	bs.Req.Request.Body = ioutil.NopCloser(bytes.NewReader(bs.ReqBody))
+	return nil
+}
+
 // Read 合并ReadQueryEntity 和ReadEntity
+// multipart/form-data请求体使用ReadMultipart, gRPC-Web及Connect请求按协议解码
 func (bs *Context) Read(schema interface{}) (err error) {
+	if bs.rpc != nil {
+		return bs.rpc.read(bs, schema)
+	}
 	switch bs.ReadRequest().Method {
 	case http.MethodGet, http.MethodDelete, http.MethodHead:
 		return bs.ReadQueryEntity(schema)
 	}
+	if bs.isMultipart() {
+		return bs.ReadMultipart(schema)
+	}
 	return bs.ReadEntity(schema)
 }
 
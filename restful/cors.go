@@ -0,0 +1,297 @@
+package restful
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-mesh/openlogging"
+)
+
+// 跨域配置, 配置变化时立即生效
+// 可通过 cse.restful.cors.versions.<路由版本>.allowedOrigins 等配置单个版本, 未配置的项使用全局配置
+const (
+	CorsConfigPrefix      = "cse.restful.cors"
+	corsEnable            = "enable"
+	corsAllowedOrigins    = "allowedOrigins"   // 逗号分隔的源, 支持 * 及 https://*.example.com
+	corsAllowedMethods    = "allowedMethods"   // 逗号分隔的请求方式
+	corsAllowedHeaders    = "allowedHeaders"   // 逗号分隔的请求头域, 追加到默认头域之后
+	corsExposeHeaders     = "exposeHeaders"    // 逗号分隔的响应头域, 追加到默认头域之后
+	corsAllowCredentials  = "allowCredentials" // 是否允许携带cookie
+	corsMaxAge            = "maxAge"           // 预检结果缓存秒数
+	defaultCorsMaxAge     = 600
+	corsVersionsConfigKey = CorsConfigPrefix + ".versions"
+)
+
+// DefaultCorsAllowedMethods 默认允许的跨域请求方式
+var DefaultCorsAllowedMethods = []string{
+	http.MethodGet,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodHead,
+}
+
+// DefaultCorsAllowedHeaders 默认允许的跨域请求头域, 包含所有可通过的paasport头域
+var DefaultCorsAllowedHeaders = append([]string{
+	Header_content_type,
+	"accept",
+	Header_trace,
+	Header_method,
+	Header_grpc_timeout,
+	Header_x_request_timeout,
+}, AllowHeaderList...)
+
+// DefaultCorsExposeHeaders 默认允许浏览器读取的响应头域
+var DefaultCorsExposeHeaders = []string{
+	Header_trace,
+	Header_retry_after,
+	Header_ratelimit_limit,
+	Header_ratelimit_remaining,
+	Header_ratelimit_reset,
+	Header_grpc_status,
+	Header_grpc_message,
+}
+
+// CorsConfig 跨域配置
+type CorsConfig struct {
+	Enable           bool
+	AllowedOrigins   []string // 为空或包含 * 时允许所有源
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           int
+}
+
+// corsPolicy 单个路由版本的跨域规则
+type corsPolicy struct {
+	allowAll bool
+	origins  []*regexp.Regexp
+	cors     restful.CrossOriginResourceSharing
+}
+
+// originPattern 将源转为正则, *.匹配任意多级子域名, 未指定协议时匹配http及https
+func originPattern(origin string) (*regexp.Regexp, error) {
+	origin = strings.TrimSuffix(strings.ToLower(origin), "/")
+	scheme := "https?://"
+	if i := strings.Index(origin, "://"); i >= 0 {
+		scheme = regexp.QuoteMeta(origin[:i+3])
+		origin = origin[i+3:]
+	}
+	pattern := regexp.QuoteMeta(origin)
+	pattern = strings.Replace(pattern, `\*\.`, `([a-z0-9-]+\.)+`, -1)
+	pattern = strings.Replace(pattern, `\*`, `[a-z0-9-]+`, -1)
+	return regexp.Compile("^" + scheme + pattern + "$")
+}
+
+// mergeHeaders 合并头域并去重
+func mergeHeaders(lists ...[]string) []string {
+	var headers []string
+	seen := make(map[string]bool)
+	for _, list := range lists {
+		for _, h := range list {
+			h = strings.ToLower(strings.TrimSpace(h))
+			if h != "" && !seen[h] {
+				seen[h] = true
+				headers = append(headers, h)
+			}
+		}
+	}
+	return headers
+}
+
+func newCorsPolicy(config CorsConfig) *corsPolicy {
+	if !config.Enable {
+		return nil
+	}
+	p := &corsPolicy{allowAll: len(config.AllowedOrigins) == 0}
+	for _, origin := range config.AllowedOrigins {
+		if origin = strings.TrimSpace(origin); origin == "*" {
+			p.allowAll = true
+			continue
+		}
+		pattern, err := originPattern(origin)
+		if err != nil {
+			openlogging.GetLogger().Warnf("invalid cors origin '%s': %s", origin, err.Error())
+			continue
+		}
+		p.origins = append(p.origins, pattern)
+	}
+	if p.allowAll && config.AllowCredentials {
+		openlogging.GetLogger().Warnf("cors allows credentials from any origin")
+	}
+	var methods []string
+	for _, method := range config.AllowedMethods {
+		if method = strings.TrimSpace(method); method != "" {
+			methods = append(methods, strings.ToUpper(method))
+		}
+	}
+	if len(methods) == 0 {
+		methods = DefaultCorsAllowedMethods
+	}
+	maxAge := config.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultCorsMaxAge
+	}
+	// 源已经在allowed中校验, AllowedDomains为空即放行
+	p.cors = restful.CrossOriginResourceSharing{
+		AllowedMethods: methods,
+		AllowedHeaders: mergeHeaders(DefaultCorsAllowedHeaders, config.AllowedHeaders),
+		ExposeHeaders:  mergeHeaders(DefaultCorsExposeHeaders, config.ExposeHeaders),
+		CookiesAllowed: config.AllowCredentials,
+		MaxAge:         maxAge,
+	}
+	return p
+}
+
+// allowed 源是否允许跨域
+func (p *corsPolicy) allowed(origin string) bool {
+	if p.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, pattern := range p.origins {
+		if pattern.MatchString(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// cors 全局及各路由版本的跨域规则
+type cors struct {
+	mu       sync.RWMutex
+	configs  map[string]*CorsConfig // 通过SetCors设置的规则, 优先于配置
+	policies map[string]*corsPolicy
+}
+
+func newCors() *cors {
+	return &cors{
+		configs:  make(map[string]*CorsConfig),
+		policies: make(map[string]*corsPolicy),
+	}
+}
+
+// Event 配置变化时清空缓存的跨域规则
+func (c *cors) Event(e *event.Event) {
+	openlogging.GetLogger().Infof("cors config '%s' changed, reload", e.Key)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.policies = make(map[string]*corsPolicy)
+}
+
+// loadCorsConfig 从配置中加载路由版本的跨域配置, 版本为空时为全局配置
+func loadCorsConfig(version string) CorsConfig {
+	get := func(key string) (string, bool) {
+		if version != "" {
+			if k := strings.Join([]string{corsVersionsConfigKey, version, key}, "."); archaius.Exist(k) {
+				return archaius.GetString(k, ""), true
+			}
+		}
+		k := CorsConfigPrefix + "." + key
+		return archaius.GetString(k, ""), archaius.Exist(k)
+	}
+	getBool := func(key string) bool {
+		v, _ := get(key)
+		return strings.EqualFold(v, "true")
+	}
+	config := CorsConfig{
+		Enable:           getBool(corsEnable),
+		AllowCredentials: getBool(corsAllowCredentials),
+	}
+	if v, ok := get(corsAllowedOrigins); ok {
+		config.AllowedOrigins = splitList(v)
+	}
+	if v, ok := get(corsAllowedMethods); ok {
+		config.AllowedMethods = splitList(v)
+	}
+	if v, ok := get(corsAllowedHeaders); ok {
+		config.AllowedHeaders = splitList(v)
+	}
+	if v, ok := get(corsExposeHeaders); ok {
+		config.ExposeHeaders = splitList(v)
+	}
+	if v, ok := get(corsMaxAge); ok {
+		if maxAge, err := strconv.Atoi(v); err == nil {
+			config.MaxAge = maxAge
+		} else {
+			openlogging.GetLogger().Warnf("invalid cors max age '%s'", v)
+		}
+	}
+	return config
+}
+
+// policy 获取路由版本的跨域规则, 未开启时返回nil
+func (c *cors) policy(version string) *corsPolicy {
+	c.mu.RLock()
+	p, ok := c.policies[version]
+	c.mu.RUnlock()
+	if ok {
+		return p
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	config, ok := c.configs[version]
+	if !ok && version != "" {
+		config, ok = c.configs[""]
+	}
+	if ok {
+		p = newCorsPolicy(*config)
+	} else {
+		p = newCorsPolicy(loadCorsConfig(version))
+	}
+	c.policies[version] = p
+	return p
+}
+
+// filter go-restful容器过滤器, 预检请求在此直接响应, 不进入处理链
+func (c *cors) filter(version func(path string) string) restful.FilterFunction {
+	return func(req *restful.Request, rep *restful.Response, chain *restful.FilterChain) {
+		origin := req.Request.Header.Get(restful.HEADER_Origin)
+		if origin == "" {
+			chain.ProcessFilter(req, rep)
+			return
+		}
+		p := c.policy(version(req.Request.URL.Path))
+		if p == nil {
+			chain.ProcessFilter(req, rep)
+			return
+		}
+		rep.AddHeader(Header_vary, "Origin")
+		if !p.allowed(origin) {
+			chain.ProcessFilter(req, rep)
+			return
+		}
+		p.cors.Filter(req, rep, chain)
+	}
+}
+
+// routeVersion 请求路径对应的路由版本, 没有对应的版本时为空
+func (r *RestfulServer) routeVersion(path string) string {
+	segment := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+	if segment == "" {
+		return ""
+	}
+	for _, ws := range r.GetWebService() {
+		if strings.Trim(ws.RootPath(), "/") == segment {
+			return segment
+		}
+	}
+	return ""
+}
+
+// SetCors 设置路由版本的跨域规则, 版本为空时为全局规则
+// 设置后该版本不再使用配置中的跨域规则
+func (r *RestfulServer) SetCors(version string, config CorsConfig) {
+	r.cors.mu.Lock()
+	defer r.cors.mu.Unlock()
+	r.cors.configs[version] = &config
+	r.cors.policies = make(map[string]*corsPolicy)
+}
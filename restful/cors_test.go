@@ -0,0 +1,97 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOriginPattern(t *testing.T) {
+	for origin, cases := range map[string]map[string]bool{
+		"https://*.example.com": {
+			"https://a.example.com":      true,
+			"https://a.b.example.com":    true,
+			"https://example.com":        false,
+			"http://a.example.com":       false,
+			"https://a.example.com.evil": false,
+		},
+		"example.com": {
+			"https://example.com":      true,
+			"http://example.com":       true,
+			"https://evil-example.com": false,
+		},
+		"http://localhost:*": {
+			"http://localhost:8080": true,
+			"http://localhost":      false,
+		},
+	} {
+		pattern, err := originPattern(origin)
+		assert.NoError(t, err)
+		for o, match := range cases {
+			assert.Equal(t, match, pattern.MatchString(o), origin+" "+o)
+		}
+	}
+}
+
+func TestCors(t *testing.T) {
+	initLogger()
+	r := &RestfulServer{cors: newCors()}
+	r.filters = append(r.filters, r.cors.filter(r.routeVersion))
+	var hits int
+	handler := func(req *restful.Request, rep *restful.Response) {
+		hits++
+		rep.WriteHeader(http.StatusOK)
+	}
+	assert.NoError(t, r.apply([]routeEntry{
+		{route: Route{Method: http.MethodGet, Path: "/hello", Version: "v1"}, handler: handler},
+		{route: Route{Method: http.MethodGet, Path: "/hello", Version: "v2"}, handler: handler},
+	}))
+	r.SetCors("", CorsConfig{
+		Enable:           true,
+		AllowedOrigins:   []string{"https://*.example.com"},
+		AllowCredentials: true,
+		MaxAge:           60,
+	})
+	r.SetCors("v2", CorsConfig{})
+
+	call := func(method, path, origin string, header http.Header) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		req.Header.Set(restful.HEADER_Origin, origin)
+		rw := httptest.NewRecorder()
+		r.ServeHTTP(rw, req)
+		return rw
+	}
+
+	preflight := http.Header{
+		restful.HEADER_AccessControlRequestMethod:  {http.MethodGet},
+		restful.HEADER_AccessControlRequestHeaders: {"Content-Type, Paasport-App-Id"},
+	}
+	rw := call(http.MethodOptions, "/v1/hello", "https://web.example.com", preflight)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 0, hits)
+	assert.Equal(t, "https://web.example.com", rw.Header().Get(restful.HEADER_AccessControlAllowOrigin))
+	assert.Equal(t, "true", rw.Header().Get(restful.HEADER_AccessControlAllowCredentials))
+	assert.Equal(t, "60", rw.Header().Get(restful.HEADER_AccessControlMaxAge))
+	assert.Contains(t, rw.Header().Get(restful.HEADER_AccessControlAllowMethods), http.MethodPatch)
+
+	rw = call(http.MethodOptions, "/v1/hello", "https://evil.com", preflight)
+	assert.Empty(t, rw.Header().Get(restful.HEADER_AccessControlAllowOrigin))
+
+	rw = call(http.MethodGet, "/v1/hello", "https://web.example.com", nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 1, hits)
+	assert.Equal(t, "https://web.example.com", rw.Header().Get(restful.HEADER_AccessControlAllowOrigin))
+	assert.Contains(t, rw.Header().Get(restful.HEADER_AccessControlExposeHeaders), Header_trace)
+	assert.Equal(t, "Origin", rw.Header().Get(Header_vary))
+
+	rw = call(http.MethodGet, "/v2/hello", "https://web.example.com", nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, 2, hits)
+	assert.Empty(t, rw.Header().Get(restful.HEADER_AccessControlAllowOrigin))
+}
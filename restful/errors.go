@@ -7,19 +7,42 @@ import (
 )
 
 const (
-	INTERNAL_ERR             = 10401 // 服务器内部错误
-	HEADER_MISSING_ERR       = 10402 //缺少头域
-	TOKEN_ISEMPTY_ERR        = 10403 // token为空
-	DECODE_TOKEN_FAIL        = 10404 // token解码失败
-	PARSE_TOKEN_ERR          = 10405 // token解析失败
-	DECODE_CLAIM_FAIL        = 10406 // token claim解码失败
-	PARSE_CLAIM_FAIL         = 10407 // token claim解析失败
-	INVALID_ERR_FORMAT_ERR   = 10408 // 无效的错误格式
-	INVALID_PATH_ARG_ERR     = 10409 // 无效的路径参数
-	MAINTENANCE_ERR          = 10410 // 服务维护中
-	INVALID_GRAPHQL_BODY_ERR = 10411 // 无效的graphql请求体
+	INTERNAL_ERR                 = 10401 // 服务器内部错误
+	HEADER_MISSING_ERR           = 10402 //缺少头域
+	TOKEN_ISEMPTY_ERR            = 10403 // token为空
+	DECODE_TOKEN_FAIL            = 10404 // token解码失败
+	PARSE_TOKEN_ERR              = 10405 // token解析失败
+	DECODE_CLAIM_FAIL            = 10406 // token claim解码失败
+	PARSE_CLAIM_FAIL             = 10407 // token claim解析失败
+	INVALID_ERR_FORMAT_ERR       = 10408 // 无效的错误格式
+	INVALID_PATH_ARG_ERR         = 10409 // 无效的路径参数
+	MAINTENANCE_ERR              = 10410 // 服务维护中
+	INVALID_GRAPHQL_BODY_ERR     = 10411 // 无效的graphql请求体
+	TIMEOUT_ERR                  = 10412 // 请求超时
+	RATE_LIMIT_ERR               = 10413 // 请求过于频繁
+	INVALID_CONTENT_ENCODING_ERR = 10414 // 无效的请求体编码
+	BODY_TOO_LARGE_ERR           = 10415 // 请求体过大
+	INVALID_MULTIPART_ERR        = 10416 // 无效的multipart请求体
+	INVALID_FIELD_MASK_ERR       = 10417 // 无效的响应字段掩码
+	PRECONDITION_FAILED_ERR      = 10418 // 条件请求不满足
+	INVALID_REQUEST_BODY_ERR     = 10419 // 无效的请求体
+	INVALID_QUERY_ARG_ERR        = 10420 // 无效的查询参数
 )
 
+// 需要使用特定http状态码的错误码
+var errCodeHTTPStatus = map[int]int{
+	BODY_TOO_LARGE_ERR: http.StatusRequestEntityTooLarge,
+}
+
+// httpStatusFromError 根据grpc状态码及错误码获取http状态码
+func httpStatusFromError(b *Context, code codes.Code, errCode int) int {
+	httpCode := HTTPStatusFromCode(b, code)
+	if status, ok := errCodeHTTPStatus[errCode]; ok && httpCode != http.StatusOK {
+		return status
+	}
+	return httpCode
+}
+
 // HTTPStatusFromCode converts a gRPC error code into the corresponding HTTP response status.
 // See: https://github.com/googleapis/googleapis/blob/master/google/rpc/code.proto
 // 如果query参数中携带ihc参数且不为空则忽略http状态码
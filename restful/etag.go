@@ -0,0 +1,153 @@
+package restful
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-mesh/openlogging"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// 透传给gRPC实现的条件请求头域
+var preconditionHeaders = []string{Header_if_match, Header_if_unmodified_since}
+
+// etagSpec GET路由的ETag规则
+type etagSpec struct {
+	field string // 作为ETag的响应字段, 为空时根据响应内容计算
+}
+
+// routeETag 根据路由元数据生成ETag规则, 非GET/HEAD路由或关闭时返回nil
+func routeETag(route Route) *etagSpec {
+	if route.Method != http.MethodGet && route.Method != http.MethodHead {
+		return nil
+	}
+	if route.Metadata[ETAG_METADATA] == "false" {
+		return nil
+	}
+	return &etagSpec{field: route.Metadata[ETAG_FIELD_METADATA]}
+}
+
+// tag 返回响应的ETag, 指定字段取自完整的响应, 作为资源版本使用强ETag
+// 计算时使用裁剪后的数据, 信封格式及压缩编码不同的响应内容相同, 使用弱ETag
+func (e *etagSpec) tag(resp, data interface{}) string {
+	if e.field != "" {
+		if m, ok := resp.(proto.Message); ok {
+			msg := proto.MessageV2(m).ProtoReflect()
+			if fd := msg.Descriptor().Fields().ByName(protoreflect.Name(e.field)); fd != nil && msg.Has(fd) {
+				return quoteETag(msg.Get(fd).String())
+			}
+		}
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		openlogging.GetLogger().Warnf("marshal response for etag failed: %s", err.Error())
+		return ""
+	}
+	sum := sha256.Sum256(body)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// quoteETag 未加引号的值作为强ETag
+func quoteETag(tag string) string {
+	if strings.HasPrefix(tag, `"`) || strings.HasPrefix(tag, `W/"`) {
+		return tag
+	}
+	return `"` + tag + `"`
+}
+
+// etagMatch If-None-Match是否匹配, 使用弱比较
+func etagMatch(ifNoneMatch, tag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == strings.TrimPrefix(tag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified 写入ETag头域, If-None-Match匹配时以304响应并返回true
+func (e *etagSpec) notModified(b *Context, resp, data interface{}) bool {
+	if e == nil || data == nil {
+		return false
+	}
+	tag := e.tag(resp, data)
+	if tag == "" {
+		return false
+	}
+	b.Resp.Header().Set(Header_etag, tag)
+	ifNoneMatch := b.ReadHeader(Header_if_none_match)
+	if ifNoneMatch == "" || !etagMatch(ifNoneMatch, tag) {
+		return false
+	}
+	b.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// isConditionalWrite 是否为需要透传条件头域的写请求
+func isConditionalWrite(method string) bool {
+	return method == http.MethodPut || method == http.MethodPatch || method == http.MethodDelete
+}
+
+// preconditionContext 将写请求的If-Match及If-Unmodified-Since写入gRPC的incoming metadata
+func preconditionContext(ctx context.Context, req *http.Request) context.Context {
+	if !isConditionalWrite(req.Method) {
+		return ctx
+	}
+	md := metadata.MD{}
+	for _, h := range preconditionHeaders {
+		if v := req.Header.Get(h); v != "" {
+			md.Set(h, v)
+		}
+	}
+	if md.Len() == 0 {
+		return ctx
+	}
+	if in, ok := metadata.FromIncomingContext(ctx); ok {
+		md = metadata.Join(in, md)
+	}
+	return metadata.NewIncomingContext(ctx, md)
+}
+
+// CheckPrecondition 在gRPC实现中根据资源当前的ETag及修改时间校验If-Match及If-Unmodified-Since
+// 不满足时返回FailedPrecondition, 响应412
+func CheckPrecondition(ctx context.Context, etag string, modified time.Time) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(Header_if_match); len(values) > 0 {
+		if etag == "" || !ifMatch(values[0], etag) {
+			return status.Errorf(codes.FailedPrecondition, "(%d)etag does not match", PRECONDITION_FAILED_ERR)
+		}
+		return nil
+	}
+	if values := md.Get(Header_if_unmodified_since); len(values) > 0 && !modified.IsZero() {
+		since, err := http.ParseTime(values[0])
+		if err == nil && modified.Truncate(time.Second).After(since) {
+			return status.Errorf(codes.FailedPrecondition, "(%d)resource modified since %s", PRECONDITION_FAILED_ERR, values[0])
+		}
+	}
+	return nil
+}
+
+// ifMatch If-Match使用强比较, 弱ETag不匹配
+func ifMatch(header, etag string) bool {
+	etag = quoteETag(etag)
+	if strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || t == etag {
+			return true
+		}
+	}
+	return false
+}
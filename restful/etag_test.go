@@ -0,0 +1,79 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestResponseETag(t *testing.T) {
+	initLogger()
+	upload := &Upload{Filename: "a.txt", ContentType: "text/plain", Size: 1}
+	etag := routeETag(Route{Method: http.MethodGet})
+
+	bs, rw := newHttpBodyContext(http.MethodGet, "/upload", nil, "")
+	bs.etag = etag
+	Response(bs, upload, nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	tag := rw.Header().Get(Header_etag)
+	assert.True(t, strings.HasPrefix(tag, `W/"`), tag)
+
+	// If-None-Match使用弱比较
+	bs, rw = newHttpBodyContext(http.MethodGet, "/upload", http.Header{"If-None-Match": {`"other", ` + strings.TrimPrefix(tag, "W/")}}, "")
+	bs.etag = etag
+	Response(bs, upload, nil)
+	assert.Equal(t, http.StatusNotModified, rw.Code)
+	assert.Empty(t, rw.Body.String())
+	assert.Equal(t, tag, rw.Header().Get(Header_etag))
+
+	// 字段掩码不同时响应内容不同
+	bs, rw = newHttpBodyContext(http.MethodGet, "/upload?fields=filename", http.Header{"If-None-Match": {tag}}, "")
+	bs.etag = etag
+	Response(bs, upload, nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.NotEqual(t, tag, rw.Header().Get(Header_etag))
+
+	bs, rw = newHttpBodyContext(http.MethodGet, "/upload", nil, "")
+	bs.etag = routeETag(Route{Method: http.MethodGet, Metadata: map[string]string{ETAG_FIELD_METADATA: "filename"}})
+	Response(bs, upload, nil)
+	assert.Equal(t, `"a.txt"`, rw.Header().Get(Header_etag))
+
+	assert.Nil(t, routeETag(Route{Method: http.MethodPut}))
+	assert.Nil(t, routeETag(Route{Method: http.MethodGet, Metadata: map[string]string{ETAG_METADATA: "false"}}))
+}
+
+func TestCheckPrecondition(t *testing.T) {
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	newCtx := func(method string, header http.Header) context.Context {
+		r, _ := http.NewRequest(method, "/upload", nil)
+		r.Header = header
+		return preconditionContext(context.TODO(), r)
+	}
+	code := func(err error) codes.Code {
+		return status.Code(err)
+	}
+
+	ctx := newCtx(http.MethodPut, http.Header{"If-Match": {`"v1"`}})
+	assert.NoError(t, CheckPrecondition(ctx, "v1", modified))
+	assert.Equal(t, codes.FailedPrecondition, code(CheckPrecondition(ctx, "v2", modified)))
+	assert.Equal(t, codes.FailedPrecondition, code(CheckPrecondition(ctx, `W/"v1"`, modified)))
+
+	ctx = newCtx(http.MethodDelete, http.Header{"If-Match": {"*"}})
+	assert.NoError(t, CheckPrecondition(ctx, "v1", modified))
+	assert.Error(t, CheckPrecondition(ctx, "", modified))
+
+	ctx = newCtx(http.MethodPatch, http.Header{"If-Unmodified-Since": {modified.Format(http.TimeFormat)}})
+	assert.NoError(t, CheckPrecondition(ctx, "v1", modified))
+	_, errCode, _ := ParseError(CheckPrecondition(ctx, "v1", modified.Add(time.Minute)))
+	assert.Equal(t, PRECONDITION_FAILED_ERR, errCode)
+
+	// GET请求不透传
+	ctx = newCtx(http.MethodGet, http.Header{"If-Match": {`"v1"`}})
+	assert.NoError(t, CheckPrecondition(ctx, "v2", modified))
+}
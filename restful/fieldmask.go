@@ -0,0 +1,153 @@
+package restful
+
+import (
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// fieldMask 字段掩码树, 子树为空时保留整个字段
+type fieldMask map[string]fieldMask
+
+// add 添加一个proto字段名组成的路径, 已保留整个字段时忽略子路径
+func (m fieldMask) add(path []string) {
+	for i, name := range path {
+		sub, ok := m[name]
+		if ok && len(sub) == 0 {
+			return
+		}
+		if i == len(path)-1 {
+			m[name] = fieldMask{}
+			return
+		}
+		if !ok {
+			sub = fieldMask{}
+			m[name] = sub
+		}
+		m = sub
+	}
+}
+
+func invalidFieldMask(format string, args ...interface{}) error {
+	return status.Errorf(codes.InvalidArgument, "(%d)"+format, append([]interface{}{INVALID_FIELD_MASK_ERR}, args...)...)
+}
+
+// readFieldMask 读取fields或$fields查询参数中的字段路径
+// 请求消息中有fields字段时只读取$fields
+func readFieldMask(b *Context) []string {
+	query := b.ReadRequest().URL.Query()
+	keys := []string{FIELDS_QUERY_PARAM, FIELDS_QUERY_PARAM_ALT}
+	if b.fieldsReserved {
+		keys = keys[1:]
+	}
+	var paths []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			paths = append(paths, splitList(value)...)
+		}
+	}
+	return paths
+}
+
+// newFieldMask 根据消息描述校验字段路径, 路径中可使用proto字段名或json名
+// 只有非repeated的消息字段可以继续指定子字段
+func newFieldMask(desc protoreflect.MessageDescriptor, paths []string) (fieldMask, error) {
+	mask := fieldMask{}
+	for _, p := range paths {
+		segments := strings.Split(p, ".")
+		names := make([]string, 0, len(segments))
+		md := desc
+		for i, seg := range segments {
+			if md == nil {
+				return nil, invalidFieldMask("field path '%s' can not select sub fields of '%s'", p, strings.Join(segments[:i], "."))
+			}
+			fd := md.Fields().ByName(protoreflect.Name(seg))
+			if fd == nil {
+				fd = md.Fields().ByJSONName(seg)
+			}
+			if fd == nil {
+				return nil, invalidFieldMask("unknown field path '%s' in %s", p, desc.FullName())
+			}
+			names = append(names, string(fd.Name()))
+			md = nil
+			if fd.Kind() == protoreflect.MessageKind && fd.Cardinality() != protoreflect.Repeated {
+				md = fd.Message()
+			}
+		}
+		mask.add(names)
+	}
+	return mask, nil
+}
+
+// prune 清除不在掩码中的字段
+func (m fieldMask) prune(msg protoreflect.Message) {
+	var clear []protoreflect.FieldDescriptor
+	var nested []protoreflect.FieldDescriptor
+	msg.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		sub, ok := m[string(fd.Name())]
+		if !ok {
+			clear = append(clear, fd)
+		} else if len(sub) > 0 {
+			nested = append(nested, fd)
+		}
+		return true
+	})
+	for _, fd := range clear {
+		msg.Clear(fd)
+	}
+	for _, fd := range nested {
+		m[string(fd.Name())].prune(msg.Mutable(fd).Message())
+	}
+}
+
+// maskResponse 按请求中的字段掩码裁剪响应, 返回裁剪后的副本, 未指定掩码时返回原响应
+func maskResponse(b *Context, resp interface{}) (interface{}, error) {
+	paths := readFieldMask(b)
+	if len(paths) == 0 || resp == nil {
+		return resp, nil
+	}
+	m, ok := resp.(proto.Message)
+	if !ok {
+		return nil, invalidFieldMask("response %T does not support field mask", resp)
+	}
+	msg := proto.MessageV2(m)
+	mask, err := newFieldMask(msg.ProtoReflect().Descriptor(), paths)
+	if err != nil {
+		return nil, err
+	}
+	clone := protov2.Clone(msg)
+	mask.prune(clone.ProtoReflect())
+	return proto.MessageV1(clone), nil
+}
+
+// FieldMaskParameters 文档中声明的字段掩码查询参数
+func FieldMaskParameters() []*Parameters {
+	return []*Parameters{
+		{Name: FIELDS_QUERY_PARAM, DataType: "string", ParamType: restful.QueryParameterKind, Desc: "comma separated response fields, e.g. a,b.c"},
+		{Name: FIELDS_QUERY_PARAM_ALT, DataType: "string", ParamType: restful.QueryParameterKind, Desc: "same as fields"},
+	}
+}
+
+// FieldMaskAltParameters 请求消息中有fields字段时只声明$fields, fields作为请求字段
+func FieldMaskAltParameters() []*Parameters {
+	return FieldMaskParameters()[1:]
+}
+
+// routeFieldsReserved 路由只声明了$fields时, fields属于请求消息, 不作为字段掩码
+func routeFieldsReserved(route Route) bool {
+	var fields, alt bool
+	for _, p := range route.Parameters {
+		switch p.Name {
+		case FIELDS_QUERY_PARAM:
+			fields = true
+		case FIELDS_QUERY_PARAM_ALT:
+			alt = true
+		}
+	}
+	return alt && !fields
+}
@@ -0,0 +1,105 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestMaskResponse(t *testing.T) {
+	upload := &Upload{Filename: "a.txt", ContentType: "text/plain", Size: 1, Data: []byte("a")}
+
+	bs, _ := newHttpBodyContext(http.MethodGet, "/upload?fields=filename,contentType", nil, "")
+	masked, err := maskResponse(bs, upload)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(&Upload{Filename: "a.txt", ContentType: "text/plain"}, masked.(proto.Message)))
+	assert.Equal(t, int64(1), upload.Size)
+
+	bs, _ = newHttpBodyContext(http.MethodGet, "/upload", nil, "")
+	masked, err = maskResponse(bs, upload)
+	assert.NoError(t, err)
+	assert.True(t, masked == upload)
+
+	bs, _ = newHttpBodyContext(http.MethodGet, "/upload?$fields=filename,unknown", nil, "")
+	_, err = maskResponse(bs, upload)
+	_, errCode, _ := ParseError(err)
+	assert.Equal(t, INVALID_FIELD_MASK_ERR, errCode)
+
+	bs, _ = newHttpBodyContext(http.MethodGet, "/upload?fields=filename", nil, "")
+	_, err = maskResponse(bs, &struct{ Filename string }{})
+	_, errCode, _ = ParseError(err)
+	assert.Equal(t, INVALID_FIELD_MASK_ERR, errCode)
+
+	// 请求消息中有fields字段时只读取$fields
+	bs, _ = newHttpBodyContext(http.MethodGet, "/upload?fields=unknown", nil, "")
+	bs.fieldsReserved = true
+	masked, err = maskResponse(bs, upload)
+	assert.NoError(t, err)
+	assert.True(t, masked == upload)
+
+	bs, _ = newHttpBodyContext(http.MethodGet, "/upload?fields=unknown&$fields=filename", nil, "")
+	bs.fieldsReserved = true
+	masked, err = maskResponse(bs, upload)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(&Upload{Filename: "a.txt"}, masked.(proto.Message)))
+}
+
+func TestRouteFieldsReserved(t *testing.T) {
+	assert.False(t, routeFieldsReserved(Route{}))
+	assert.False(t, routeFieldsReserved(Route{Parameters: FieldMaskParameters()}))
+	assert.True(t, routeFieldsReserved(Route{Parameters: FieldMaskAltParameters()}))
+}
+
+func TestMaskResponseNested(t *testing.T) {
+	desc := &descriptorpb.DescriptorProto{
+		Name:    proto.String("Hello"),
+		Field:   []*descriptorpb.FieldDescriptorProto{{Name: proto.String("name")}},
+		Options: &descriptorpb.MessageOptions{Deprecated: proto.Bool(true), MapEntry: proto.Bool(true)},
+	}
+
+	bs, _ := newHttpBodyContext(http.MethodGet, "/desc?fields=options.deprecated,field", nil, "")
+	masked, err := maskResponse(bs, desc)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(&descriptorpb.DescriptorProto{
+		Field:   desc.Field,
+		Options: &descriptorpb.MessageOptions{Deprecated: proto.Bool(true)},
+	}, masked.(proto.Message)))
+
+	// 同时指定整个字段及子字段时保留整个字段
+	bs, _ = newHttpBodyContext(http.MethodGet, "/desc?fields=options.deprecated&$fields=options", nil, "")
+	masked, err = maskResponse(bs, desc)
+	assert.NoError(t, err)
+	assert.True(t, proto.Equal(&descriptorpb.DescriptorProto{Options: desc.Options}, masked.(proto.Message)))
+
+	bs, _ = newHttpBodyContext(http.MethodGet, "/desc?fields=field.name", nil, "")
+	_, err = maskResponse(bs, desc)
+	_, errCode, _ := ParseError(err)
+	assert.Equal(t, INVALID_FIELD_MASK_ERR, errCode)
+}
+
+func TestResponseFieldMask(t *testing.T) {
+	initLogger()
+	upload := &Upload{Filename: "a.txt", ContentType: "text/plain", Size: 1}
+
+	bs, rw := newHttpBodyContext(http.MethodGet, "/upload?fields=filename", nil, "")
+	Response(bs, upload, nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.JSONEq(t, `{"filename":"a.txt"}`, rw.Body.String())
+
+	bs, rw = newHttpBodyContext(http.MethodGet, "/upload?fields=filename&onebox=1", nil, "")
+	Response(bs, upload, nil)
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+	assert.Equal(t, map[string]interface{}{"filename": "a.txt"}, body.Data)
+
+	bs, rw = newHttpBodyContext(http.MethodGet, "/upload?fields=unknown", nil, "")
+	Response(bs, upload, nil)
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), "10417")
+}
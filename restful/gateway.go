@@ -0,0 +1,249 @@
+package restful
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-mesh/openlogging"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Gateway 根据描述符中的http注解动态注册路由, 无需重新生成代码
+// 请求以json转码为动态消息后通过gRPC连接调用远程服务, 描述符变化后重新加载即可替换路由
+type Gateway struct {
+	conn   grpc.ClientConnInterface
+	source DescriptorSource
+	// BodyLimit 请求体的最大字节数, 0为不限制
+	BodyLimit int64
+
+	// 加载串行执行
+	loadMu sync.Mutex
+	loaded bool
+	digest [sha256.Size]byte
+	// 当前的路由, 重新加载时整体替换, 由mux保护
+	mux       sync.RWMutex
+	container *restful.Container
+	routes    *routeTable
+}
+
+// NewGateway 新建动态网关, 调用Load后开始提供路由
+// @conn 远程服务的连接
+// @source 描述符来源
+func NewGateway(conn grpc.ClientConnInterface, source DescriptorSource) *Gateway {
+	return &Gateway{
+		conn:      conn,
+		source:    source,
+		container: restful.NewContainer(),
+		routes:    newRouteTable(),
+	}
+}
+
+// Load 加载描述符并替换路由, 描述符未变化时不做修改
+// 加载失败时保持原有的路由, 进行中的请求继续使用旧的路由
+func (g *Gateway) Load(ctx context.Context) error {
+	g.loadMu.Lock()
+	defer g.loadMu.Unlock()
+	set, err := g.source(ctx)
+	if err != nil {
+		return err
+	}
+	raw, err := protov2.MarshalOptions{Deterministic: true}.Marshal(set)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(raw)
+	if g.loaded && digest == g.digest {
+		return nil
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return err
+	}
+	entries, routes, err := g.build(files)
+	if err != nil {
+		return err
+	}
+	wss := []*restful.WebService{new(restful.WebService)}
+	for _, e := range entries {
+		if wss, err = registe2WebService(wss, e.route, e.handler); err != nil {
+			return err
+		}
+	}
+	container := restful.NewContainer()
+	for _, ws := range wss {
+		container.Add(ws)
+	}
+	g.mux.Lock()
+	g.container = container
+	g.routes = routes
+	g.mux.Unlock()
+	g.loaded = true
+	g.digest = digest
+	openlogging.GetLogger().Infof("gateway loaded %d routes from %d files", len(entries), files.NumFiles())
+	return nil
+}
+
+// Watch 按间隔重新加载描述符, 直到ctx结束, 间隔不大于0时不重新加载
+func (g *Gateway) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		openlogging.GetLogger().Warnf("gateway watch skipped, invalid interval %s", interval)
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := g.Load(ctx); err != nil {
+				openlogging.GetLogger().Warnf("gateway reload failed: %s", err.Error())
+			}
+		}
+	}
+}
+
+// build 为所有带http注解的一元方法生成路由
+func (g *Gateway) build(files *protoregistry.Files) ([]routeEntry, *routeTable, error) {
+	var entries []routeEntry
+	routes := newRouteTable()
+	var err error
+	files.RangeFiles(func(fd protoreflect.FileDescriptor) bool {
+		services := fd.Services()
+		for i := 0; i < services.Len(); i++ {
+			methods := services.Get(i).Methods()
+			for j := 0; j < methods.Len(); j++ {
+				if err = g.addMethod(files, methods.Get(j), &entries, routes); err != nil {
+					return false
+				}
+			}
+		}
+		return true
+	})
+	return entries, routes, err
+}
+
+// addMethod 生成方法的路由, 流式方法及不支持的http方法跳过
+func (g *Gateway) addMethod(files *protoregistry.Files, md protoreflect.MethodDescriptor, entries *[]routeEntry, routes *routeTable) error {
+	bindings, err := methodBindings(files, md)
+	if err != nil {
+		return err
+	}
+	if len(bindings) == 0 {
+		return nil
+	}
+	if md.IsStreamingClient() || md.IsStreamingServer() {
+		openlogging.GetLogger().Warnf("gateway skip streaming method %s", md.FullName())
+		return nil
+	}
+	schema := string(md.Parent().FullName())
+	for _, b := range bindings {
+		if !httpMethodSupported(b.method) {
+			openlogging.GetLogger().Warnf("gateway skip %s %s of %s, method not supported", b.method, b.path, md.FullName())
+			continue
+		}
+		path, err := routePath(b.path)
+		if err != nil {
+			return fmt.Errorf("method %s: %s", md.FullName(), err.Error())
+		}
+		route := Route{
+			Method:           b.method,
+			Path:             path,
+			ResourceFuncName: string(md.Name()),
+			FuncDesc:         b.doc,
+			Version:          b.version,
+			Parameters:       gatewayFieldMaskParameters(md),
+			Metadata:         b.metadata,
+		}
+		handler, err := g.handler(route, b, md)
+		if err != nil {
+			return err
+		}
+		*entries = withEntry(*entries, routeEntry{route: route, handler: handler})
+		routes.add(schema, route)
+	}
+	return nil
+}
+
+// handler 将请求转码为动态消息并调用远程方法
+func (g *Gateway) handler(route Route, binding httpBinding, md protoreflect.MethodDescriptor) (restful.RouteFunction, error) {
+	timeout, err := routeTimeout(route)
+	if err != nil {
+		return nil, err
+	}
+	var responseField protoreflect.FieldDescriptor
+	if binding.responseBody != "" {
+		responseField = findField(md.Output(), binding.responseBody)
+		if responseField == nil || responseField.Message() == nil || responseField.IsList() || responseField.IsMap() {
+			return nil, fmt.Errorf("method %s: response_body '%s' must be a message field", md.FullName(), binding.responseBody)
+		}
+	}
+	etag := routeETag(route)
+	fieldsReserved := routeFieldsReserved(route)
+	observer := newRouteObserver(route, string(md.Parent().FullName()))
+	fullMethod := fmt.Sprintf("/%s/%s", md.Parent().FullName(), md.Name())
+	return func(req *restful.Request, rep *restful.Response) {
+		defer recoverHandler(req, rep, route, observer)
+		if g.BodyLimit > 0 && req.Request.ContentLength > g.BodyLimit {
+			Response(newContext(req, rep), nil, ErrBodyTooLarge)
+			return
+		}
+		ctx, cancel := newRequestContext(req.Request.Context(), req.Request, timeout)
+		defer cancel()
+		req.Request = req.Request.WithContext(ctx)
+		limitBody(req.Request, g.BodyLimit)
+		bs := newContext(req, rep)
+		bs.etag = etag
+		bs.fieldsReserved = fieldsReserved
+
+		in := dynamicpb.NewMessage(md.Input())
+		if err := transcodeRequest(req, binding, in); err != nil {
+			Response(bs, nil, err)
+			return
+		}
+		out := dynamicpb.NewMessage(md.Output())
+		if err := g.conn.Invoke(OutgoingContext(bs), fullMethod, in, out); err != nil {
+			Response(bs, nil, err)
+			return
+		}
+		var resp proto.Message = out
+		if responseField != nil {
+			resp = proto.MessageV1(out.Get(responseField).Message().Interface())
+		}
+		Response(bs, resp, nil)
+	}, nil
+}
+
+// gatewayFieldMaskParameters 请求消息中有fields字段时只声明$fields
+func gatewayFieldMaskParameters(md protoreflect.MethodDescriptor) []*Parameters {
+	if md.Input().Fields().ByName(FIELDS_QUERY_PARAM) != nil {
+		return FieldMaskAltParameters()
+	}
+	return FieldMaskParameters()
+}
+
+// ListRoutes 获取当前加载的路由, 路由的schema为gRPC服务的全名
+func (g *Gateway) ListRoutes(filters ...RouteFilter) []RouteInfo {
+	g.mux.RLock()
+	defer g.mux.RUnlock()
+	return g.routes.list(filters...)
+}
+
+// ServeHTTP 使用当前的路由处理请求
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	g.mux.RLock()
+	container := g.container
+	g.mux.RUnlock()
+	container.ServeHTTP(w, req)
+}
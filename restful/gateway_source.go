@@ -0,0 +1,127 @@
+package restful
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// reflectionServiceName gRPC反射服务, 不生成路由
+const reflectionServiceName = "grpc.reflection.v1alpha.ServerReflection"
+
+// DescriptorSource 动态网关的描述符来源, 返回的集合需包含所有依赖的文件
+type DescriptorSource func(ctx context.Context) (*descriptorpb.FileDescriptorSet, error)
+
+// FileDescriptorSource 从文件中读取描述符集合
+// 文件由 protoc --include_imports --descriptor_set_out 生成
+func FileDescriptorSource(path string) DescriptorSource {
+	return func(ctx context.Context) (*descriptorpb.FileDescriptorSet, error) {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		set := new(descriptorpb.FileDescriptorSet)
+		if err := protov2.Unmarshal(b, set); err != nil {
+			return nil, fmt.Errorf("invalid descriptor set '%s': %s", path, err.Error())
+		}
+		return set, nil
+	}
+}
+
+// ReflectionDescriptorSource 通过gRPC反射获取服务及其依赖的描述符
+// 服务端需注册反射服务, 如 reflection.Register(s)
+func ReflectionDescriptorSource(conn *grpc.ClientConn) DescriptorSource {
+	return func(ctx context.Context) (*descriptorpb.FileDescriptorSet, error) {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defer stream.CloseSend()
+		r := &reflectionResolver{stream: stream, files: make(map[string]*descriptorpb.FileDescriptorProto)}
+
+		resp, err := r.call(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{ListServices: "*"}})
+		if err != nil {
+			return nil, err
+		}
+		for _, service := range resp.GetListServicesResponse().GetService() {
+			if service.GetName() == reflectionServiceName {
+				continue
+			}
+			if err := r.resolve(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service.GetName()},
+			}); err != nil {
+				return nil, err
+			}
+		}
+		set := new(descriptorpb.FileDescriptorSet)
+		for _, name := range r.order {
+			set.File = append(set.File, r.files[name])
+		}
+		return set, nil
+	}
+}
+
+// reflectionResolver 依次请求文件及其缺少的依赖
+type reflectionResolver struct {
+	stream rpb.ServerReflection_ServerReflectionInfoClient
+	files  map[string]*descriptorpb.FileDescriptorProto
+	order  []string
+}
+
+func (r *reflectionResolver) call(req *rpb.ServerReflectionRequest) (*rpb.ServerReflectionResponse, error) {
+	if err := r.stream.Send(req); err != nil {
+		return nil, err
+	}
+	resp, err := r.stream.Recv()
+	if err != nil {
+		return nil, err
+	}
+	if e := resp.GetErrorResponse(); e != nil {
+		return nil, fmt.Errorf("reflection error %d: %s", e.GetErrorCode(), e.GetErrorMessage())
+	}
+	return resp, nil
+}
+
+// resolve 请求文件描述符, 并递归请求未获取的依赖
+func (r *reflectionResolver) resolve(req *rpb.ServerReflectionRequest) error {
+	resp, err := r.call(req)
+	if err != nil {
+		return err
+	}
+	files := resp.GetFileDescriptorResponse().GetFileDescriptorProto()
+	if len(files) == 0 {
+		return errors.New("reflection returned no file descriptor")
+	}
+	var deps []string
+	for _, b := range files {
+		fd := new(descriptorpb.FileDescriptorProto)
+		if err := protov2.Unmarshal(b, fd); err != nil {
+			return err
+		}
+		if _, ok := r.files[fd.GetName()]; ok {
+			continue
+		}
+		r.files[fd.GetName()] = fd
+		r.order = append(r.order, fd.GetName())
+		deps = append(deps, fd.GetDependency()...)
+	}
+	for _, dep := range deps {
+		if _, ok := r.files[dep]; ok {
+			continue
+		}
+		if err := r.resolve(&rpb.ServerReflectionRequest{
+			MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dep},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package restful
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func gatewayField(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, typeName string, repeated bool) *descriptorpb.FieldDescriptorProto {
+	label := descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL
+	if repeated {
+		label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED
+	}
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     protov2.String(name),
+		JsonName: protov2.String(name),
+		Number:   protov2.Int32(number),
+		Type:     typ.Enum(),
+		Label:    label.Enum(),
+	}
+	if typeName != "" {
+		f.TypeName = protov2.String(typeName)
+	}
+	return f
+}
+
+func gatewayMethod(name string, rule *annotations.HttpRule) *descriptorpb.MethodDescriptorProto {
+	opts := &descriptorpb.MethodOptions{}
+	protov2.SetExtension(opts, annotations.E_Http, rule)
+	return &descriptorpb.MethodDescriptorProto{
+		Name:       protov2.String(name),
+		InputType:  protov2.String(".gwtest.HelloRequest"),
+		OutputType: protov2.String(".gwtest.HelloReply"),
+		Options:    opts,
+	}
+}
+
+// gatewayFile 测试服务的描述符, echoPath为Echo方法的路径
+func gatewayFile(echoPath string) *descriptorpb.FileDescriptorProto {
+	str := descriptorpb.FieldDescriptorProto_TYPE_STRING
+	msg := descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	return &descriptorpb.FileDescriptorProto{
+		Name:       protov2.String("gwtest/gateway.proto"),
+		Package:    protov2.String("gwtest"),
+		Dependency: []string{"google/api/annotations.proto"},
+		Syntax:     protov2.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: protov2.String("Inner"), Field: []*descriptorpb.FieldDescriptorProto{
+				gatewayField("v", 1, str, "", false),
+			}},
+			{Name: protov2.String("HelloRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				gatewayField("name", 1, str, "", false),
+				gatewayField("age", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, "", false),
+				gatewayField("tags", 3, str, "", true),
+				gatewayField("inner", 4, msg, ".gwtest.Inner", false),
+			}},
+			{Name: protov2.String("HelloReply"), Field: []*descriptorpb.FieldDescriptorProto{
+				gatewayField("message", 1, str, "", false),
+				gatewayField("request", 2, msg, ".gwtest.HelloRequest", false),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: protov2.String("Greeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{
+				gatewayMethod("Hello", &annotations.HttpRule{
+					Pattern:            &annotations.HttpRule_Post{Post: "/v1/hello/{name}"},
+					Body:               "*",
+					AdditionalBindings: []*annotations.HttpRule{{Pattern: &annotations.HttpRule_Get{Get: "/v1/hello/{name}"}}},
+				}),
+				gatewayMethod("Echo", &annotations.HttpRule{
+					Pattern:      &annotations.HttpRule_Get{Get: echoPath},
+					ResponseBody: "request",
+				}),
+			},
+		}},
+	}
+}
+
+func gatewaySet(echoPath string) *descriptorpb.FileDescriptorSet {
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{
+		protodesc.ToFileDescriptorProto(descriptorpb.File_google_protobuf_descriptor_proto),
+		protodesc.ToFileDescriptorProto(annotations.File_google_api_http_proto),
+		protodesc.ToFileDescriptorProto(annotations.File_google_api_annotations_proto),
+		gatewayFile(echoPath),
+	}}
+}
+
+// newGatewayBackend 启动使用动态消息实现的gRPC服务, 回复中带上请求及x-auth-token
+func newGatewayBackend(t *testing.T) (*grpc.ClientConn, func()) {
+	files, err := protodesc.NewFiles(gatewaySet("/v1/echo/{inner.v}"))
+	assert.NoError(t, err)
+	reqDesc, _ := files.FindDescriptorByName("gwtest.HelloRequest")
+	replyDesc, _ := files.FindDescriptorByName("gwtest.HelloReply")
+	handler := func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+		in := dynamicpb.NewMessage(reqDesc.(protoreflect.MessageDescriptor))
+		if err := dec(in); err != nil {
+			return nil, err
+		}
+		md, _ := metadata.FromIncomingContext(ctx)
+		out := dynamicpb.NewMessage(replyDesc.(protoreflect.MessageDescriptor))
+		fields := out.Descriptor().Fields()
+		out.Set(fields.ByName("message"), protoreflect.ValueOfString("hello "+strings.Join(md.Get(Header_x_auth_token), ",")))
+		out.Set(fields.ByName("request"), protoreflect.ValueOfMessage(in))
+		return out, nil
+	}
+	raw, err := protov2.Marshal(gatewayFile("/v1/echo/{inner.v}"))
+	assert.NoError(t, err)
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	w.Write(raw)
+	w.Close()
+
+	s := grpc.NewServer()
+	s.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "gwtest.Greeter",
+		HandlerType: (*interface{})(nil),
+		Methods: []grpc.MethodDesc{
+			{MethodName: "Hello", Handler: handler},
+			{MethodName: "Echo", Handler: handler},
+		},
+		Metadata: gz.Bytes(),
+	}, struct{}{})
+	reflection.Register(s)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	go s.Serve(l)
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	assert.NoError(t, err)
+	return conn, func() {
+		conn.Close()
+		s.Stop()
+	}
+}
+
+func writeGatewaySet(t *testing.T, path, echoPath string) {
+	raw, err := protov2.Marshal(gatewaySet(echoPath))
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(path, raw, 0644))
+}
+
+func gatewayDo(g *Gateway, method, target, body string) (int, map[string]interface{}) {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set(Header_content_type, "application/json")
+	req.Header.Set("Authorization", "Bearer abc")
+	rw := httptest.NewRecorder()
+	g.ServeHTTP(rw, req)
+	var out map[string]interface{}
+	json.Unmarshal(rw.Body.Bytes(), &out)
+	return rw.Code, out
+}
+
+func TestGatewayFileSource(t *testing.T) {
+	initLogger()
+	conn, stop := newGatewayBackend(t)
+	defer stop()
+	dir, err := ioutil.TempDir("", "gateway")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "gateway.pb")
+	writeGatewaySet(t, path, "/v1/echo/{inner.v}")
+
+	g := NewGateway(conn, FileDescriptorSource(path))
+	code, _ := gatewayDo(g, http.MethodGet, "/v1/hello/bob", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	assert.NoError(t, g.Load(context.TODO()))
+	assert.Len(t, g.ListRoutes(WithRouteSchema("gwtest.Greeter")), 3)
+
+	code, out := gatewayDo(g, http.MethodPost, "/v1/hello/bob", `{"age":3,"tags":["a"],"unknown":1}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "hello Bearer abc", out["message"])
+	assert.Equal(t, map[string]interface{}{"name": "bob", "age": float64(3), "tags": []interface{}{"a"}}, out["request"])
+
+	code, out = gatewayDo(g, http.MethodGet, "/v1/hello/bob?age=5&tags=a&tags=b&inner.v=x&fields=request", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"request": map[string]interface{}{
+		"name": "bob", "age": float64(5), "tags": []interface{}{"a", "b"}, "inner": map[string]interface{}{"v": "x"},
+	}}, out)
+
+	code, out = gatewayDo(g, http.MethodGet, "/v1/echo/xyz", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"inner": map[string]interface{}{"v": "xyz"}}, out)
+
+	code, out = gatewayDo(g, http.MethodPost, "/v1/hello/bob", `{"age":"old"}`)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, float64(INVALID_REQUEST_BODY_ERR), out["err_code"])
+	code, out = gatewayDo(g, http.MethodGet, "/v1/hello/bob?age=old", "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, float64(INVALID_QUERY_ARG_ERR), out["err_code"])
+
+	// 描述符变化后重新加载, 加载失败时保持原有路由
+	writeGatewaySet(t, path, "/v2/echo/{inner.v}")
+	assert.NoError(t, g.Load(context.TODO()))
+	code, _ = gatewayDo(g, http.MethodGet, "/v1/echo/xyz", "")
+	assert.Equal(t, http.StatusNotFound, code)
+	code, _ = gatewayDo(g, http.MethodGet, "/v2/echo/xyz", "")
+	assert.Equal(t, http.StatusOK, code)
+	writeGatewaySet(t, path, "/v3/echo/{inner.v}:verb")
+	assert.Error(t, g.Load(context.TODO()))
+	code, _ = gatewayDo(g, http.MethodGet, "/v2/echo/xyz", "")
+	assert.Equal(t, http.StatusOK, code)
+}
+
+func TestGatewayReflectionSource(t *testing.T) {
+	initLogger()
+	conn, stop := newGatewayBackend(t)
+	defer stop()
+	g := NewGateway(conn, ReflectionDescriptorSource(conn))
+	assert.NoError(t, g.Load(context.TODO()))
+	assert.Len(t, g.ListRoutes(), 3)
+	code, out := gatewayDo(g, http.MethodGet, "/v1/echo/xyz", "")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"inner": map[string]interface{}{"v": "xyz"}}, out)
+}
+
+func TestGatewayWatchInvalidInterval(t *testing.T) {
+	initLogger()
+	g := NewGateway(nil, FileDescriptorSource("missing.pb"))
+	// 间隔无效时直接返回
+	g.Watch(context.TODO(), 0)
+	g.Watch(context.TODO(), -time.Second)
+}
+
+func TestRoutePath(t *testing.T) {
+	for template, want := range map[string]string{
+		"/v1/hello/{name}":             "/v1/hello/{name}",
+		"/v1/hello/{name=*}/x":         "/v1/hello/{name}/x",
+		"/v1/files/{path=**}":          "/v1/files/{path:*}",
+		"/v1/{name=shelves/*/books/*}": "/v1/{name:*}",
+	} {
+		got, err := routePath(template)
+		assert.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	for _, template := range []string{"/v1/{name=shelves/*}/books", "/v1/{name}:cancel", "/v1/}{"} {
+		_, err := routePath(template)
+		assert.Error(t, err, template)
+	}
+}
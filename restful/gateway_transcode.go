@@ -0,0 +1,409 @@
+package restful
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	protov2 "google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// HttpRuleExtensions 动态网关读取的http注解, 按顺序使用第一个存在的注解
+// 注解的定义需包含在描述符集合中
+var HttpRuleExtensions = []protoreflect.FullName{"restful.http", "google.api.http"}
+
+// 动态网关不作为请求字段的查询参数
+var reservedQueryParams = map[string]bool{
+	FIELDS_QUERY_PARAM:     true,
+	FIELDS_QUERY_PARAM_ALT: true,
+	BODY_INONEBOX_PARAM:    true,
+	IGNORE_HTTP_CODE_PARAM: true,
+	LANGUAGE_QUERY_PARAM:   true,
+}
+
+// errFieldNotFound 请求消息中没有参数对应的字段
+var errFieldNotFound = errors.New("field not found")
+
+// httpBinding 注解中的一个http绑定
+type httpBinding struct {
+	method       string
+	path         string
+	body         string
+	responseBody string
+	version      string
+	doc          string
+	metadata     map[string]string
+}
+
+// methodBindings 读取方法上的http注解, 包括additional_bindings
+func methodBindings(files *protoregistry.Files, md protoreflect.MethodDescriptor) ([]httpBinding, error) {
+	rule, err := methodHttpRule(files, md)
+	if rule == nil || err != nil {
+		return nil, err
+	}
+	parent := httpBinding{
+		version:  ruleString(rule, "version"),
+		doc:      ruleString(rule, "doc"),
+		metadata: ruleMetadata(rule),
+	}
+	bindings := []httpBinding{newHttpBinding(rule, parent)}
+	if fd := rule.Descriptor().Fields().ByName("additional_bindings"); fd != nil && fd.IsList() && fd.Message() != nil {
+		list := rule.Get(fd).List()
+		for i := 0; i < list.Len(); i++ {
+			bindings = append(bindings, newHttpBinding(list.Get(i).Message(), parent))
+		}
+	}
+	var valid []httpBinding
+	for _, b := range bindings {
+		if b.method != "" && b.path != "" {
+			valid = append(valid, b)
+		}
+	}
+	return valid, nil
+}
+
+// methodHttpRule 从方法选项中解析http注解, 没有注解时返回nil
+// 注解以动态消息读取, 不依赖注解的Go类型
+func methodHttpRule(files *protoregistry.Files, md protoreflect.MethodDescriptor) (protoreflect.Message, error) {
+	opts := md.Options()
+	if opts == nil {
+		return nil, nil
+	}
+	raw, err := protov2.Marshal(opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range HttpRuleExtensions {
+		d, err := files.FindDescriptorByName(name)
+		if err != nil {
+			continue
+		}
+		xd, ok := d.(protoreflect.ExtensionDescriptor)
+		if !ok || xd.Message() == nil {
+			continue
+		}
+		value, found := extensionBytes(raw, xd.Number())
+		if !found {
+			continue
+		}
+		rule := dynamicpb.NewMessage(xd.Message())
+		if err := protov2.Unmarshal(value, rule); err != nil {
+			return nil, fmt.Errorf("invalid %s option on %s: %s", name, md.FullName(), err.Error())
+		}
+		return rule, nil
+	}
+	return nil, nil
+}
+
+// extensionBytes 合并选项中指定字段号的消息内容
+func extensionBytes(b []byte, number protowire.Number) ([]byte, bool) {
+	var value []byte
+	found := false
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, false
+		}
+		b = b[n:]
+		if num == number && typ == protowire.BytesType {
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return nil, false
+			}
+			value = append(value, v...)
+			found = true
+			b = b[n:]
+			continue
+		}
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return nil, false
+		}
+		b = b[n:]
+	}
+	return value, found
+}
+
+// newHttpBinding 解析绑定的http方法及路径, 版本、说明及元数据未设置时继承parent
+func newHttpBinding(rule protoreflect.Message, parent httpBinding) httpBinding {
+	b := httpBinding{
+		body:         ruleString(rule, "body"),
+		responseBody: ruleString(rule, "response_body"),
+		version:      parent.version,
+		doc:          parent.doc,
+		metadata:     parent.metadata,
+	}
+	for _, verb := range []protoreflect.Name{"get", "put", "post", "delete", "patch", "head"} {
+		if path := ruleString(rule, verb); path != "" {
+			b.method, b.path = strings.ToUpper(string(verb)), path
+		}
+	}
+	if fd := rule.Descriptor().Fields().ByName("custom"); fd != nil && fd.Message() != nil && rule.Has(fd) {
+		custom := rule.Get(fd).Message()
+		b.method, b.path = strings.ToUpper(ruleString(custom, "kind")), ruleString(custom, "path")
+	}
+	if v := ruleString(rule, "version"); v != "" {
+		b.version = v
+	}
+	if v := ruleString(rule, "doc"); v != "" {
+		b.doc = v
+	}
+	if m := ruleMetadata(rule); len(m) > 0 {
+		b.metadata = m
+	}
+	return b
+}
+
+// ruleString 读取注解中的字符串字段, 字段不存在时为空
+func ruleString(m protoreflect.Message, name protoreflect.Name) string {
+	fd := m.Descriptor().Fields().ByName(name)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+		return ""
+	}
+	return m.Get(fd).String()
+}
+
+// ruleMetadata 读取restful.http注解中的metadata
+func ruleMetadata(m protoreflect.Message) map[string]string {
+	fd := m.Descriptor().Fields().ByName("metadata")
+	if fd == nil || !fd.IsList() || fd.Message() == nil {
+		return nil
+	}
+	metadata := make(map[string]string)
+	list := m.Get(fd).List()
+	for i := 0; i < list.Len(); i++ {
+		item := list.Get(i).Message()
+		if field, value := ruleString(item, "field"), ruleString(item, "value"); field != "" && value != "" {
+			metadata[field] = value
+		}
+	}
+	return metadata
+}
+
+// routePath 将注解的路径模板转换为go-restful的路径
+// {name} 及 {name=*} 匹配一段路径, 末尾的 {name=**} 或 {name=shelves/*} 等匹配剩余的路径
+func routePath(template string) (string, error) {
+	if i := strings.LastIndex(template, ":"); i > strings.LastIndex(template, "}") {
+		return "", fmt.Errorf("custom verb in path template '%s' not supported", template)
+	}
+	var out strings.Builder
+	rest := template
+	for {
+		start := strings.Index(rest, "{")
+		if start < 0 {
+			out.WriteString(rest)
+			return out.String(), nil
+		}
+		end := strings.Index(rest, "}")
+		if end < start {
+			return "", fmt.Errorf("invalid path template '%s'", template)
+		}
+		out.WriteString(rest[:start])
+		name, pattern := rest[start+1:end], "*"
+		if i := strings.Index(name, "="); i >= 0 {
+			name, pattern = name[:i], name[i+1:]
+		}
+		rest = rest[end+1:]
+		switch {
+		case pattern == "*":
+			out.WriteString("{" + name + "}")
+		case rest == "":
+			out.WriteString("{" + name + ":*}")
+		default:
+			return "", fmt.Errorf("pattern '%s' of '%s' must be the last segment in path template '%s'", pattern, name, template)
+		}
+	}
+}
+
+// transcodeRequest 按绑定将请求体、路径参数及查询参数写入请求消息
+func transcodeRequest(req *restful.Request, binding httpBinding, msg protoreflect.Message) error {
+	if binding.body != "" && req.Request.Body != nil {
+		body, err := ioutil.ReadAll(req.Request.Body)
+		if err != nil {
+			return err
+		}
+		if err := unmarshalBody(body, binding.body, msg); err != nil {
+			return status.Errorf(codes.InvalidArgument, "(%d)invalid request body: %s", INVALID_REQUEST_BODY_ERR, err.Error())
+		}
+	}
+	for name, value := range req.PathParameters() {
+		if err := setFieldPath(msg, name, []string{value}); err != nil {
+			return status.Errorf(codes.InvalidArgument, "(%d)invalid path parameter '%s': %s", INVALID_PATH_ARG_ERR, name, err.Error())
+		}
+	}
+	// 请求体映射到整个消息时查询参数不作为请求字段
+	if binding.body == "*" {
+		return nil
+	}
+	for name, values := range req.Request.URL.Query() {
+		// 请求消息中有fields字段时fields作为请求字段
+		if reservedQueryParams[name] && !(name == FIELDS_QUERY_PARAM && msg.Descriptor().Fields().ByName(FIELDS_QUERY_PARAM) != nil) {
+			continue
+		}
+		if err := setFieldPath(msg, name, values); err != nil && err != errFieldNotFound {
+			return status.Errorf(codes.InvalidArgument, "(%d)invalid query parameter '%s': %s", INVALID_QUERY_ARG_ERR, name, err.Error())
+		}
+	}
+	return nil
+}
+
+// unmarshalBody 将json请求体写入整个消息或body指定的字段, 忽略未知字段
+func unmarshalBody(body []byte, field string, msg protoreflect.Message) error {
+	if len(strings.TrimSpace(string(body))) == 0 {
+		return nil
+	}
+	if field != "*" {
+		fd := findField(msg.Descriptor(), field)
+		if fd == nil {
+			return fmt.Errorf("body field '%s' not found", field)
+		}
+		name, _ := json.Marshal(fd.JSONName())
+		body = []byte("{" + string(name) + ":" + string(body) + "}")
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, msg.Interface())
+}
+
+// findField 按proto字段名或json字段名查找字段
+func findField(md protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := md.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return md.Fields().ByJSONName(name)
+}
+
+// setFieldPath 设置以点分隔的字段路径, 中间的字段需为单个消息
+func setFieldPath(msg protoreflect.Message, path string, values []string) error {
+	names := strings.Split(path, ".")
+	for _, name := range names[:len(names)-1] {
+		fd := findField(msg.Descriptor(), name)
+		if fd == nil {
+			return errFieldNotFound
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return fmt.Errorf("field '%s' is not a message", name)
+		}
+		msg = msg.Mutable(fd).Message()
+	}
+	fd := findField(msg.Descriptor(), names[len(names)-1])
+	if fd == nil {
+		return errFieldNotFound
+	}
+	if fd.IsMap() {
+		return fmt.Errorf("map field '%s' not supported", fd.Name())
+	}
+	if fd.IsList() {
+		list := msg.Mutable(fd).List()
+		for _, v := range values {
+			value, err := parseFieldValue(fd, v)
+			if err != nil {
+				return err
+			}
+			list.Append(value)
+		}
+		return nil
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	value, err := parseFieldValue(fd, values[len(values)-1])
+	if err != nil {
+		return err
+	}
+	msg.Set(fd, value)
+	return nil
+}
+
+// parseFieldValue 将字符串解析为字段的值
+// 消息类型仅支持以json字符串表示的消息, 如Timestamp、Duration及包装类型
+func parseFieldValue(fd protoreflect.FieldDescriptor, s string) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(s), nil
+	case protoreflect.BytesKind:
+		b, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			b, err = base64.URLEncoding.DecodeString(s)
+		}
+		return protoreflect.ValueOfBytes(b), err
+	case protoreflect.BoolKind:
+		v, err := strconv.ParseBool(s)
+		return protoreflect.ValueOfBool(v), err
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		v, err := strconv.ParseInt(s, 10, 32)
+		return protoreflect.ValueOfInt32(int32(v)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err := strconv.ParseInt(s, 10, 64)
+		return protoreflect.ValueOfInt64(v), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		v, err := strconv.ParseUint(s, 10, 32)
+		return protoreflect.ValueOfUint32(uint32(v)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err := strconv.ParseUint(s, 10, 64)
+		return protoreflect.ValueOfUint64(v), err
+	case protoreflect.FloatKind:
+		v, err := strconv.ParseFloat(s, 32)
+		return protoreflect.ValueOfFloat32(float32(v)), err
+	case protoreflect.DoubleKind:
+		v, err := strconv.ParseFloat(s, 64)
+		return protoreflect.ValueOfFloat64(v), err
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(s)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		v, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || v > math.MaxInt32 || v < math.MinInt32 {
+			return protoreflect.Value{}, fmt.Errorf("invalid enum value '%s'", s)
+		}
+		return protoreflect.ValueOfEnum(protoreflect.EnumNumber(v)), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		value, _ := json.Marshal(s)
+		m := dynamicpb.NewMessage(fd.Message())
+		if err := protojson.Unmarshal(value, m); err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfMessage(m), nil
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %s", fd.Kind())
+}
+
+// protoJSON 以protojson序列化动态消息, 字段名与生成代码的json标签相同
+type protoJSON struct {
+	proto.Message
+}
+
+// MarshalJSON 使用proto字段名, 忽略未设置的字段
+func (m protoJSON) MarshalJSON() ([]byte, error) {
+	return protojson.MarshalOptions{UseProtoNames: true}.Marshal(proto.MessageV2(m.Message))
+}
+
+// jsonEntity 动态消息没有json标签, 响应时以protojson序列化
+func jsonEntity(resp interface{}) interface{} {
+	if m, ok := resp.(*dynamicpb.Message); ok && m != nil {
+		return protoJSON{Message: m}
+	}
+	return resp
+}
+
+// httpMethodSupported go-restful路由支持的http方法
+func httpMethodSupported(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodHead, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
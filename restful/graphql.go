@@ -0,0 +1,720 @@
+package restful
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-mesh/openlogging"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// GraphQL接口配置, 默认关闭
+// 开启后在path上提供查询, 在path/schema上提供生成的schema
+// 限制小于等于0时使用默认值
+const (
+	GraphQLEnableKey          = "cse.restful.graphql.enable"
+	GraphQLPathKey            = "cse.restful.graphql.path"
+	GraphQLMaxFieldsKey       = "cse.restful.graphql.maxFields"   // 一次请求展开片段后的最大字段数
+	GraphQLMaxDepthKey        = "cse.restful.graphql.maxDepth"    // 字段、选择集及参数值的最大嵌套深度
+	GraphQLConcurrencyKey     = "cse.restful.graphql.concurrency" // 并发执行的顶层查询字段数
+	DefaultGraphQLPath        = "/graphql"
+	DefaultGraphQLMaxFields   = 500
+	DefaultGraphQLMaxDepth    = 10
+	DefaultGraphQLConcurrency = 10
+)
+
+// GraphQL字段所属的操作
+const (
+	GraphQLQuery    = "query"
+	GraphQLMutation = "mutation"
+)
+
+// GraphQLField 生成的查询或变更字段, 通过方法的rpc路由解析
+type GraphQLField struct {
+	Operation  string // GraphQLQuery 或 GraphQLMutation
+	Name       string
+	Definition string // 字段定义, 如 hello(name: String): HelloReply
+	RPCMethod  string // 方法的gRPC全名, 与路由的rpc_method元数据相同, 如 /greeter.Greeter/Hello
+}
+
+// GraphQLSchema 生成代码中<Service>HttpHandler的GraphQLSchema返回的类型定义及字段
+type GraphQLSchema struct {
+	Types  map[string]string // 类型名及其定义
+	Fields []GraphQLField
+}
+
+// graphQLSchemaProvider 生成的<Service>HttpHandler
+type graphQLSchemaProvider interface {
+	GraphQLSchema() GraphQLSchema
+}
+
+// graphQLScalarMessages 作为GraphQL标量的消息, 与插件中的映射保持一致
+var graphQLScalarMessages = map[protoreflect.FullName]bool{
+	"google.protobuf.Timestamp":   true,
+	"google.protobuf.Duration":    true,
+	"google.protobuf.FieldMask":   true,
+	"google.protobuf.StringValue": true,
+	"google.protobuf.BytesValue":  true,
+	"google.protobuf.Int64Value":  true,
+	"google.protobuf.UInt64Value": true,
+	"google.protobuf.Int32Value":  true,
+	"google.protobuf.UInt32Value": true,
+	"google.protobuf.DoubleValue": true,
+	"google.protobuf.FloatValue":  true,
+	"google.protobuf.BoolValue":   true,
+	"google.protobuf.Struct":      true,
+	"google.protobuf.Value":       true,
+	"google.protobuf.ListValue":   true,
+	"google.protobuf.Any":         true,
+	"google.protobuf.Empty":       true,
+}
+
+// graphQLInvalid 文档无法执行时以标准错误响应
+func graphQLInvalid(format string, args ...interface{}) error {
+	return status.Errorf(codes.InvalidArgument, "(%d)invalid graphql request: %s", INVALID_GRAPHQL_BODY_ERR, fmt.Sprintf(format, args...))
+}
+
+// graphQLTypeName 消息对应的GraphQL类型名, 嵌套消息以下划线连接
+func graphQLTypeName(md protoreflect.MessageDescriptor) string {
+	name := strings.TrimPrefix(string(md.FullName()), string(md.ParentFile().Package())+".")
+	return strings.Replace(name, ".", "_", -1)
+}
+
+// graphQLLeaf 字段是否为GraphQL标量或枚举, 不能再选择子字段
+func graphQLLeaf(fd protoreflect.FieldDescriptor) bool {
+	if fd.IsMap() || fd.Message() == nil {
+		return true
+	}
+	return graphQLScalarMessages[fd.Message().FullName()] || fd.Message().Fields().Len() == 0
+}
+
+// graphQLSchemaOf 获取生成的<Service>HttpHandler的GraphQL schema
+func graphQLSchemaOf(schema interface{}) (GraphQLSchema, bool) {
+	provider, ok := schema.(graphQLSchemaProvider)
+	if !ok {
+		return GraphQLSchema{}, false
+	}
+	return provider.GraphQLSchema(), true
+}
+
+// graphQLField 字段及其方法的rpc路由
+type graphQLField struct {
+	GraphQLField
+	entry routeEntry
+}
+
+// graphQLFields 查询及变更字段表, 与路由一起替换
+type graphQLFields struct {
+	types    map[string]string
+	query    map[string]graphQLField
+	mutation map[string]graphQLField
+}
+
+// newGraphQLFields 由注册的schema及JSON-RPC方法表生成字段表, 方法不存在或已停用的字段不加入
+// 已加入的同名字段保持不变
+func newGraphQLFields(schemas []GraphQLSchema, methods map[string]routeEntry) *graphQLFields {
+	t := &graphQLFields{
+		types:    make(map[string]string),
+		query:    make(map[string]graphQLField),
+		mutation: make(map[string]graphQLField),
+	}
+	for _, s := range schemas {
+		for name, def := range s.Types {
+			t.types[name] = def
+		}
+		for _, f := range s.Fields {
+			entry, ok := methods[jsonRPCMethodName(f.RPCMethod)]
+			if !ok {
+				continue
+			}
+			fields := t.query
+			if f.Operation == GraphQLMutation {
+				fields = t.mutation
+			}
+			if _, ok := fields[f.Name]; ok {
+				openlogging.GetLogger().Errorf("graphql %s field '%s' already registered, skip", f.Operation, f.Name)
+				continue
+			}
+			fields[f.Name] = graphQLField{GraphQLField: f, entry: entry}
+		}
+	}
+	return t
+}
+
+func (t *graphQLFields) field(operation, name string) (graphQLField, bool) {
+	if operation == GraphQLMutation {
+		f, ok := t.mutation[name]
+		return f, ok
+	}
+	f, ok := t.query[name]
+	return f, ok
+}
+
+// sdl 合并所有注册的类型及字段
+func (t *graphQLFields) sdl() string {
+	var b strings.Builder
+	b.WriteString("scalar JSON\n")
+	for _, op := range []struct {
+		name   string
+		fields map[string]graphQLField
+	}{{"Query", t.query}, {"Mutation", t.mutation}} {
+		if len(op.fields) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(op.fields))
+		for name := range op.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintf(&b, "\ntype %s {\n", op.name)
+		for _, name := range names {
+			fmt.Fprintf(&b, "  %s\n", op.fields[name].Definition)
+		}
+		b.WriteString("}\n")
+	}
+	names := make([]string, 0, len(t.types))
+	for name := range t.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(&b, "\n%s\n", t.types[name])
+	}
+	return b.String()
+}
+
+// graphQL 将查询中的字段分发到方法的rpc路由, 与JSON-RPC调用相同经过完整的处理链
+type graphQL struct {
+	fields      func() *graphQLFields
+	bodyLimit   int64
+	maxFields   int
+	maxDepth    int
+	concurrency int
+}
+
+// newGraphQL 限制小于等于0时使用默认值
+func newGraphQL(fields func() *graphQLFields, bodyLimit int64, maxFields, maxDepth, concurrency int) *graphQL {
+	if maxFields <= 0 {
+		maxFields = DefaultGraphQLMaxFields
+	}
+	if maxDepth <= 0 {
+		maxDepth = DefaultGraphQLMaxDepth
+	}
+	if concurrency <= 0 {
+		concurrency = DefaultGraphQLConcurrency
+	}
+	return &graphQL{fields: fields, bodyLimit: bodyLimit, maxFields: maxFields, maxDepth: maxDepth, concurrency: concurrency}
+}
+
+// routes 查询接口支持GET及POST, schema接口只支持GET
+func (g *graphQL) routes(path string) []routeEntry {
+	return []routeEntry{
+		{route: Route{Method: http.MethodGet, Path: path}, handler: g.handle},
+		{route: Route{Method: http.MethodPost, Path: path}, handler: g.handle},
+		{route: Route{Method: http.MethodGet, Path: strings.TrimSuffix(path, "/") + "/schema"}, handler: g.handleSchema},
+	}
+}
+
+// handleSchema 以文本返回生成的schema
+func (g *graphQL) handleSchema(req *restful.Request, rep *restful.Response) {
+	rep.AddHeader(Header_content_type, "text/plain;charset=utf-8")
+	rep.WriteHeader(http.StatusOK)
+	rep.Write([]byte(g.fields().sdl()))
+}
+
+// graphQLRequest GET的查询参数或POST的请求体
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphQLError 执行中的错误, extensions中带有gRPC状态码及错误码
+type graphQLError struct {
+	Message    string                 `json:"message"`
+	Path       []interface{}          `json:"path,omitempty"`
+	Extensions map[string]interface{} `json:"extensions,omitempty"`
+}
+
+type graphQLResponse struct {
+	Data   interface{}     `json:"data"`
+	Errors []*graphQLError `json:"errors,omitempty"`
+}
+
+// handle 处理查询, 请求体无效或文档无法执行时以标准错误响应, 字段出错时在errors中返回
+func (g *graphQL) handle(req *restful.Request, rep *restful.Response) {
+	bs := newContext(req, rep)
+	gr, err := g.readRequest(req)
+	if err != nil {
+		Response(bs, nil, err)
+		return
+	}
+	doc, err := parseGraphQL(gr.Query, g.maxDepth)
+	if err != nil {
+		Response(bs, nil, graphQLInvalid("%s", err.Error()))
+		return
+	}
+	op, err := doc.operation(gr.OperationName)
+	if err != nil {
+		Response(bs, nil, err)
+		return
+	}
+	if op.kind != GraphQLQuery && op.kind != GraphQLMutation {
+		Response(bs, nil, graphQLInvalid("%s is not supported", op.kind))
+		return
+	}
+	if op.kind == GraphQLMutation && req.Request.Method != http.MethodPost {
+		Response(bs, nil, graphQLInvalid("mutation must use POST"))
+		return
+	}
+	e := &graphQLExecutor{g: g, fields: g.fields(), doc: doc, op: op, req: req.Request, variables: op.variableValues(gr.Variables)}
+	fields, err := e.collect(op.selections, make(map[string]bool))
+	if err == nil {
+		err = e.validate(fields)
+	}
+	if err == nil {
+		count := 0
+		err = e.limit(fields, 1, &count)
+	}
+	if err != nil {
+		Response(bs, nil, err)
+		return
+	}
+	data := e.execute(fields)
+	traceResponse(bs.Ctx, codes.OK, 0)
+	setResult(req, codes.OK, 0)
+	bs.WriteHeaderAndJSON(http.StatusOK, graphQLResponse{Data: data, Errors: e.errors}, "application/json;charset=utf-8")
+}
+
+// readRequest 读取GET的查询参数, POST的json或application/graphql请求体
+func (g *graphQL) readRequest(req *restful.Request) (*graphQLRequest, error) {
+	gr := new(graphQLRequest)
+	if req.Request.Method == http.MethodGet {
+		query := req.Request.URL.Query()
+		gr.Query = query.Get("query")
+		gr.OperationName = query.Get("operationName")
+		if v := query.Get("variables"); v != "" {
+			if err := json.Unmarshal([]byte(v), &gr.Variables); err != nil {
+				return nil, graphQLInvalid("invalid variables: %s", err.Error())
+			}
+		}
+	} else {
+		if req.Request.Body == nil {
+			req.Request.Body = http.NoBody
+		}
+		limitBody(req.Request, g.bodyLimit)
+		body, err := ioutil.ReadAll(req.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		mediaType, _, _ := mime.ParseMediaType(req.Request.Header.Get(Header_content_type))
+		if mediaType == "application/graphql" {
+			gr.Query = string(body)
+		} else {
+			d := json.NewDecoder(bytes.NewReader(body))
+			d.UseNumber()
+			if err := d.Decode(gr); err != nil {
+				return nil, graphQLInvalid("invalid body: %s", err.Error())
+			}
+		}
+	}
+	if strings.TrimSpace(gr.Query) == "" {
+		return nil, graphQLInvalid("missing query")
+	}
+	return gr, nil
+}
+
+// operation 按名称选择操作, 未指定名称时文档中只能有一个操作
+func (d *gqlDocument) operation(name string) (*gqlOperation, error) {
+	if name == "" {
+		if len(d.operations) != 1 {
+			return nil, graphQLInvalid("operationName is required for multiple operations")
+		}
+		return d.operations[0], nil
+	}
+	for _, op := range d.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, graphQLInvalid("operation '%s' not found", name)
+}
+
+// variableValues 合并请求中的变量及默认值
+func (op *gqlOperation) variableValues(values map[string]interface{}) map[string]interface{} {
+	vars := make(map[string]interface{}, len(op.variables))
+	for _, def := range op.variables {
+		if v, ok := values[def.name]; ok {
+			vars[def.name] = v
+		} else if def.hasDefault {
+			vars[def.name] = resolveGraphQLValue(def.defaultValue, nil)
+		}
+	}
+	return vars
+}
+
+// resolveGraphQLValue 将参数值转换为json值, 变量替换为请求中的值, 未提供的变量为null
+func resolveGraphQLValue(v interface{}, vars map[string]interface{}) interface{} {
+	switch value := v.(type) {
+	case gqlVariable:
+		return vars[string(value)]
+	case gqlEnum:
+		return string(value)
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, each := range value {
+			list[i] = resolveGraphQLValue(each, vars)
+		}
+		return list
+	case gqlObjectValue:
+		obj := make(map[string]interface{}, len(value))
+		for _, field := range value {
+			obj[field.name] = resolveGraphQLValue(field.value, vars)
+		}
+		return obj
+	}
+	return v
+}
+
+// gqlField 合并后的字段, 相同响应名的字段合并子字段
+type gqlField struct {
+	key        string
+	name       string
+	arguments  []gqlArgument
+	selections []*gqlSelection
+}
+
+// graphQLObject 按选择顺序输出字段的对象
+type graphQLObject struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (o *graphQLObject) set(key string, value interface{}) {
+	o.keys = append(o.keys, key)
+	o.values[key] = value
+}
+
+// MarshalJSON 按字段顺序序列化
+func (o *graphQLObject) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range o.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		b.Write(k)
+		b.WriteByte(':')
+		v, err := json.Marshal(o.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// graphQLExecutor 执行一个操作
+type graphQLExecutor struct {
+	g         *graphQL
+	fields    *graphQLFields
+	doc       *gqlDocument
+	op        *gqlOperation
+	req       *http.Request
+	variables map[string]interface{}
+	mu        sync.Mutex
+	errors    []*graphQLError
+}
+
+// collect 展开片段并处理skip/include指令, 按响应名合并字段
+func (e *graphQLExecutor) collect(selections []*gqlSelection, visited map[string]bool) ([]*gqlField, error) {
+	var fields []*gqlField
+	index := make(map[string]*gqlField)
+	var walk func(selections []*gqlSelection) error
+	walk = func(selections []*gqlSelection) error {
+		for _, s := range selections {
+			if !e.included(s.directives) {
+				continue
+			}
+			switch {
+			case s.spread != "":
+				f, ok := e.doc.fragments[s.spread]
+				if !ok {
+					return graphQLInvalid("fragment '%s' not found", s.spread)
+				}
+				if visited[s.spread] {
+					return graphQLInvalid("fragment '%s' spreads itself", s.spread)
+				}
+				visited[s.spread] = true
+				err := walk(f.selections)
+				delete(visited, s.spread)
+				if err != nil {
+					return err
+				}
+			case s.inline:
+				if err := walk(s.selections); err != nil {
+					return err
+				}
+			default:
+				key := s.alias
+				if key == "" {
+					key = s.name
+				}
+				if f, ok := index[key]; ok {
+					if f.name != s.name {
+						return graphQLInvalid("fields '%s' and '%s' conflict on '%s'", f.name, s.name, key)
+					}
+					f.selections = append(f.selections, s.selections...)
+					continue
+				}
+				f := &gqlField{key: key, name: s.name, arguments: s.arguments, selections: s.selections}
+				index[key] = f
+				fields = append(fields, f)
+			}
+		}
+		return nil
+	}
+	err := walk(selections)
+	return fields, err
+}
+
+// included 处理skip及include指令
+func (e *graphQLExecutor) included(directives []gqlDirective) bool {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			continue
+		}
+		var cond bool
+		for _, arg := range d.arguments {
+			if arg.name == "if" {
+				cond, _ = resolveGraphQLValue(arg.value, e.variables).(bool)
+			}
+		}
+		if (d.name == "skip") == cond {
+			return false
+		}
+	}
+	return true
+}
+
+// validate 检查顶层字段是否存在
+func (e *graphQLExecutor) validate(fields []*gqlField) error {
+	for _, f := range fields {
+		if f.name == "__typename" {
+			continue
+		}
+		if _, ok := e.fields.field(e.op.kind, f.name); !ok {
+			return graphQLInvalid("cannot query field '%s' on type '%s'", f.name, e.rootType())
+		}
+	}
+	return nil
+}
+
+// limit 执行前检查展开片段后的字段数及嵌套深度, 递归引用的片段在超过深度时结束
+func (e *graphQLExecutor) limit(fields []*gqlField, depth int, count *int) error {
+	if depth > e.g.maxDepth {
+		return graphQLInvalid("query exceeds max depth %d", e.g.maxDepth)
+	}
+	if *count += len(fields); *count > e.g.maxFields {
+		return graphQLInvalid("query exceeds max fields %d", e.g.maxFields)
+	}
+	for _, f := range fields {
+		if len(f.selections) == 0 {
+			continue
+		}
+		sub, err := e.collect(f.selections, make(map[string]bool))
+		if err != nil {
+			return err
+		}
+		if err := e.limit(sub, depth+1, count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *graphQLExecutor) rootType() string {
+	if e.op.kind == GraphQLMutation {
+		return "Mutation"
+	}
+	return "Query"
+}
+
+// execute 执行顶层字段, 查询由concurrency个协程并发执行, 变更依次执行
+func (e *graphQLExecutor) execute(fields []*gqlField) *graphQLObject {
+	values := make([]interface{}, len(fields))
+	if e.op.kind == GraphQLMutation {
+		for i, f := range fields {
+			values[i] = e.resolve(f)
+		}
+	} else {
+		workers := e.g.concurrency
+		if workers > len(fields) {
+			workers = len(fields)
+		}
+		jobs := make(chan int)
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := range jobs {
+					values[i] = e.resolve(fields[i])
+				}
+			}()
+		}
+		for i := range fields {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+	}
+	data := &graphQLObject{values: make(map[string]interface{}, len(fields))}
+	for i, f := range fields {
+		data.set(f.key, values[i])
+	}
+	return data
+}
+
+// resolve 以字段参数调用方法的rpc路由并按子字段裁剪结果
+func (e *graphQLExecutor) resolve(f *gqlField) interface{} {
+	path := []interface{}{f.key}
+	if f.name == "__typename" {
+		return e.rootType()
+	}
+	field, _ := e.fields.field(e.op.kind, f.name)
+	args := make(map[string]interface{}, len(f.arguments))
+	for _, arg := range f.arguments {
+		args[arg.name] = resolveGraphQLValue(arg.value, e.variables)
+	}
+	raw, err := json.Marshal(args)
+	if err != nil {
+		e.fail(path, graphQLInvalid("invalid arguments: %s", err.Error()))
+		return nil
+	}
+	p := &rpcProtocol{kind: rpcGraphQL, params: raw}
+	field.entry.handler(newJSONRPCRequest(e.req, field.entry.route, p), restful.NewResponse(&discardResponseWriter{header: http.Header{}}))
+	if !p.finished {
+		openlogging.GetLogger().Errorf("graphql field '%s' finished without response", f.name)
+		e.fail(path, ErrInternal)
+		return nil
+	}
+	if p.err != nil {
+		e.fail(path, p.err)
+		return nil
+	}
+	resp := p.result
+	if resp == nil {
+		return nil
+	}
+	if v := reflect.ValueOf(resp); v.Kind() == reflect.Ptr && v.IsNil() {
+		return nil
+	}
+	m, ok := resp.(proto.Message)
+	if !ok {
+		e.fail(path, fmt.Errorf("graphql field '%s' resolved %T", f.name, resp))
+		return nil
+	}
+	md := proto.MessageV2(m).ProtoReflect().Descriptor()
+	b, err := protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}.Marshal(proto.MessageV2(m))
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var generic interface{}
+	if err := d.Decode(&generic); err != nil {
+		e.fail(path, err)
+		return nil
+	}
+	if graphQLScalarMessages[md.FullName()] || md.Fields().Len() == 0 {
+		if len(f.selections) > 0 {
+			e.fail(path, graphQLInvalid("field '%s' can not have a selection", f.name))
+			return nil
+		}
+		return generic
+	}
+	if len(f.selections) == 0 {
+		e.fail(path, graphQLInvalid("field '%s' of type '%s' must have a selection of subfields", f.name, graphQLTypeName(md)))
+		return nil
+	}
+	obj, _ := generic.(map[string]interface{})
+	return e.complete(md, obj, f.selections, path)
+}
+
+// complete 按子字段裁剪消息, 字段出错时该字段为null
+func (e *graphQLExecutor) complete(md protoreflect.MessageDescriptor, value map[string]interface{}, selections []*gqlSelection, path []interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	fields, err := e.collect(selections, make(map[string]bool))
+	if err != nil {
+		e.fail(path, err)
+		return nil
+	}
+	obj := &graphQLObject{values: make(map[string]interface{}, len(fields))}
+	for _, f := range fields {
+		fieldPath := append(append([]interface{}{}, path...), f.key)
+		if f.name == "__typename" {
+			obj.set(f.key, graphQLTypeName(md))
+			continue
+		}
+		fd := md.Fields().ByName(protoreflect.Name(f.name))
+		switch {
+		case fd == nil:
+			e.fail(fieldPath, graphQLInvalid("cannot query field '%s' on type '%s'", f.name, graphQLTypeName(md)))
+			obj.set(f.key, nil)
+		case graphQLLeaf(fd):
+			if len(f.selections) > 0 {
+				e.fail(fieldPath, graphQLInvalid("field '%s' can not have a selection", f.name))
+				obj.set(f.key, nil)
+				continue
+			}
+			obj.set(f.key, value[f.name])
+		case len(f.selections) == 0:
+			e.fail(fieldPath, graphQLInvalid("field '%s' of type '%s' must have a selection of subfields", f.name, graphQLTypeName(fd.Message())))
+			obj.set(f.key, nil)
+		case fd.IsList():
+			items, _ := value[f.name].([]interface{})
+			list := make([]interface{}, len(items))
+			for i, item := range items {
+				sub, _ := item.(map[string]interface{})
+				list[i] = e.complete(fd.Message(), sub, f.selections, append(append([]interface{}{}, fieldPath...), i))
+			}
+			obj.set(f.key, list)
+		default:
+			sub, _ := value[f.name].(map[string]interface{})
+			obj.set(f.key, e.complete(fd.Message(), sub, f.selections, fieldPath))
+		}
+	}
+	return obj
+}
+
+// fail 记录字段错误, 错误与http路由相同方式解析
+func (e *graphQLExecutor) fail(path []interface{}, err error) {
+	statusCode, errCode, formatErr := formatError(err)
+	openlogging.GetLogger().Errorf("graphql field %v got error[%s]", path, err.Error())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.errors = append(e.errors, &graphQLError{
+		Message:    status.Convert(formatErr).Message(),
+		Path:       path,
+		Extensions: map[string]interface{}{"code": int32(statusCode), "err_code": errCode},
+	})
+}
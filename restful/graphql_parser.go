@@ -0,0 +1,559 @@
+package restful
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// GraphQL查询文档的解析, 支持操作、变量、别名、参数、片段及skip/include指令
+// 类型系统定义及订阅不支持
+
+type gqlTokenKind int
+
+const (
+	gqlEOF gqlTokenKind = iota
+	gqlPunct
+	gqlName
+	gqlInt
+	gqlFloat
+	gqlString
+)
+
+type gqlToken struct {
+	kind  gqlTokenKind
+	value string
+	pos   int
+}
+
+// gqlDocument 解析后的查询文档
+type gqlDocument struct {
+	operations []*gqlOperation
+	fragments  map[string]*gqlFragment
+}
+
+type gqlOperation struct {
+	kind       string // query, mutation或subscription
+	name       string
+	variables  []gqlVariableDef
+	selections []*gqlSelection
+}
+
+type gqlVariableDef struct {
+	name         string
+	defaultValue interface{}
+	hasDefault   bool
+}
+
+type gqlFragment struct {
+	name       string
+	selections []*gqlSelection
+}
+
+// gqlSelection 字段、片段引用或内联片段
+type gqlSelection struct {
+	alias      string
+	name       string
+	arguments  []gqlArgument
+	directives []gqlDirective
+	selections []*gqlSelection
+	// 片段引用的名称
+	spread string
+	// 内联片段
+	inline bool
+}
+
+type gqlArgument struct {
+	name  string
+	value interface{}
+}
+
+type gqlDirective struct {
+	name      string
+	arguments []gqlArgument
+}
+
+// 参数值中的变量、枚举及对象, 其余为string、json.Number、bool、nil及[]interface{}
+type gqlVariable string
+
+type gqlEnum string
+
+type gqlObjectValue []gqlArgument
+
+// gqlParser 递归下降解析器
+type gqlParser struct {
+	src string
+	pos int
+	tok gqlToken
+	// 选择集、列表及对象值的嵌套深度, maxDepth为0时不限制
+	depth    int
+	maxDepth int
+}
+
+// parseGraphQL 解析查询文档, 嵌套超过maxDepth时报错
+func parseGraphQL(src string, maxDepth int) (doc *gqlDocument, err error) {
+	p := &gqlParser{src: src, maxDepth: maxDepth}
+	defer func() {
+		if e := recover(); e != nil {
+			perr, ok := e.(gqlSyntaxError)
+			if !ok {
+				panic(e)
+			}
+			doc, err = nil, perr
+		}
+	}()
+	p.next()
+	doc = &gqlDocument{fragments: make(map[string]*gqlFragment)}
+	for p.tok.kind != gqlEOF {
+		switch {
+		case p.peek(gqlPunct, "{"):
+			doc.operations = append(doc.operations, &gqlOperation{kind: "query", selections: p.parseSelectionSet()})
+		case p.peek(gqlName, "query"), p.peek(gqlName, "mutation"), p.peek(gqlName, "subscription"):
+			doc.operations = append(doc.operations, p.parseOperation())
+		case p.peek(gqlName, "fragment"):
+			f := p.parseFragment()
+			if _, ok := doc.fragments[f.name]; ok {
+				p.fail("duplicate fragment '%s'", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			p.fail("unexpected %s", p.describe())
+		}
+	}
+	if len(doc.operations) == 0 {
+		p.fail("no operation")
+	}
+	return doc, nil
+}
+
+type gqlSyntaxError struct {
+	msg string
+}
+
+func (e gqlSyntaxError) Error() string {
+	return e.msg
+}
+
+func (p *gqlParser) fail(format string, args ...interface{}) {
+	panic(gqlSyntaxError{msg: fmt.Sprintf("syntax error at %d: %s", p.tok.pos, fmt.Sprintf(format, args...))})
+}
+
+func (p *gqlParser) describe() string {
+	if p.tok.kind == gqlEOF {
+		return "end of document"
+	}
+	return "'" + p.tok.value + "'"
+}
+
+func (p *gqlParser) peek(kind gqlTokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// enter 进入一层嵌套, 超过最大深度时报错
+func (p *gqlParser) enter() {
+	p.depth++
+	if p.maxDepth > 0 && p.depth > p.maxDepth {
+		p.fail("nesting exceeds max depth %d", p.maxDepth)
+	}
+}
+
+func (p *gqlParser) leave() {
+	p.depth--
+}
+
+// expect 当前为指定的符号或关键字时读取下一个, 否则报错
+func (p *gqlParser) expect(kind gqlTokenKind, value string) {
+	if !p.peek(kind, value) {
+		p.fail("expected '%s', found %s", value, p.describe())
+	}
+	p.next()
+}
+
+func (p *gqlParser) parseName() string {
+	if p.tok.kind != gqlName {
+		p.fail("expected name, found %s", p.describe())
+	}
+	name := p.tok.value
+	p.next()
+	return name
+}
+
+func (p *gqlParser) parseOperation() *gqlOperation {
+	op := &gqlOperation{kind: p.parseName()}
+	if p.tok.kind == gqlName {
+		op.name = p.parseName()
+	}
+	if p.peek(gqlPunct, "(") {
+		p.next()
+		for !p.peek(gqlPunct, ")") {
+			p.expect(gqlPunct, "$")
+			def := gqlVariableDef{name: p.parseName()}
+			p.expect(gqlPunct, ":")
+			p.parseType()
+			if p.peek(gqlPunct, "=") {
+				p.next()
+				def.defaultValue, def.hasDefault = p.parseValue(true), true
+			}
+			p.parseDirectives()
+			op.variables = append(op.variables, def)
+		}
+		p.next()
+	}
+	p.parseDirectives()
+	op.selections = p.parseSelectionSet()
+	return op
+}
+
+// parseType 变量类型只做语法检查
+func (p *gqlParser) parseType() {
+	if p.peek(gqlPunct, "[") {
+		p.enter()
+		p.next()
+		p.parseType()
+		p.expect(gqlPunct, "]")
+		p.leave()
+	} else {
+		p.parseName()
+	}
+	if p.peek(gqlPunct, "!") {
+		p.next()
+	}
+}
+
+func (p *gqlParser) parseFragment() *gqlFragment {
+	p.expect(gqlName, "fragment")
+	f := &gqlFragment{name: p.parseName()}
+	if f.name == "on" {
+		p.fail("fragment can not be named 'on'")
+	}
+	p.expect(gqlName, "on")
+	p.parseName()
+	p.parseDirectives()
+	f.selections = p.parseSelectionSet()
+	return f
+}
+
+func (p *gqlParser) parseSelectionSet() []*gqlSelection {
+	p.enter()
+	defer p.leave()
+	p.expect(gqlPunct, "{")
+	var selections []*gqlSelection
+	for !p.peek(gqlPunct, "}") {
+		selections = append(selections, p.parseSelection())
+	}
+	p.next()
+	if len(selections) == 0 {
+		p.fail("empty selection set")
+	}
+	return selections
+}
+
+func (p *gqlParser) parseSelection() *gqlSelection {
+	if p.peek(gqlPunct, "...") {
+		p.next()
+		if p.tok.kind == gqlName && p.tok.value != "on" {
+			s := &gqlSelection{spread: p.parseName()}
+			s.directives = p.parseDirectives()
+			return s
+		}
+		s := &gqlSelection{inline: true}
+		if p.peek(gqlName, "on") {
+			p.next()
+			p.parseName()
+		}
+		s.directives = p.parseDirectives()
+		s.selections = p.parseSelectionSet()
+		return s
+	}
+	s := &gqlSelection{name: p.parseName()}
+	if p.peek(gqlPunct, ":") {
+		p.next()
+		s.alias, s.name = s.name, p.parseName()
+	}
+	s.arguments = p.parseArguments(false)
+	s.directives = p.parseDirectives()
+	if p.peek(gqlPunct, "{") {
+		s.selections = p.parseSelectionSet()
+	}
+	return s
+}
+
+func (p *gqlParser) parseArguments(constant bool) []gqlArgument {
+	if !p.peek(gqlPunct, "(") {
+		return nil
+	}
+	p.next()
+	var args []gqlArgument
+	for !p.peek(gqlPunct, ")") {
+		arg := gqlArgument{name: p.parseName()}
+		p.expect(gqlPunct, ":")
+		arg.value = p.parseValue(constant)
+		args = append(args, arg)
+	}
+	p.next()
+	return args
+}
+
+func (p *gqlParser) parseDirectives() []gqlDirective {
+	var directives []gqlDirective
+	for p.peek(gqlPunct, "@") {
+		p.next()
+		d := gqlDirective{name: p.parseName()}
+		d.arguments = p.parseArguments(false)
+		directives = append(directives, d)
+	}
+	return directives
+}
+
+// parseValue 解析参数值, constant为true时不允许变量
+func (p *gqlParser) parseValue(constant bool) interface{} {
+	tok := p.tok
+	switch tok.kind {
+	case gqlInt, gqlFloat:
+		p.next()
+		return json.Number(tok.value)
+	case gqlString:
+		p.next()
+		return tok.value
+	case gqlName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return gqlEnum(tok.value)
+	case gqlPunct:
+		switch tok.value {
+		case "$":
+			if constant {
+				p.fail("variable not allowed here")
+			}
+			p.next()
+			return gqlVariable(p.parseName())
+		case "[":
+			p.enter()
+			p.next()
+			list := []interface{}{}
+			for !p.peek(gqlPunct, "]") {
+				list = append(list, p.parseValue(constant))
+			}
+			p.next()
+			p.leave()
+			return list
+		case "{":
+			p.enter()
+			p.next()
+			obj := gqlObjectValue{}
+			for !p.peek(gqlPunct, "}") {
+				field := gqlArgument{name: p.parseName()}
+				p.expect(gqlPunct, ":")
+				field.value = p.parseValue(constant)
+				obj = append(obj, field)
+			}
+			p.next()
+			p.leave()
+			return obj
+		}
+	}
+	p.fail("unexpected %s", p.describe())
+	return nil
+}
+
+// next 读取下一个词法单元, 跳过空白、逗号及注释
+func (p *gqlParser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',' {
+			p.pos++
+			continue
+		}
+		if strings.HasPrefix(p.src[p.pos:], "\ufeff") {
+			p.pos += 3
+			continue
+		}
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = gqlToken{kind: gqlEOF, pos: start}
+		return
+	}
+	c := p.src[p.pos]
+	switch {
+	case strings.HasPrefix(p.src[p.pos:], "..."):
+		p.pos += 3
+		p.tok = gqlToken{kind: gqlPunct, value: "...", pos: start}
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		p.pos++
+		p.tok = gqlToken{kind: gqlPunct, value: string(c), pos: start}
+	case c == '_' || gqlLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || gqlLetter(p.src[p.pos]) || gqlDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = gqlToken{kind: gqlName, value: p.src[start:p.pos], pos: start}
+	case c == '-' || gqlDigit(c):
+		p.lexNumber()
+	case c == '"':
+		p.lexString()
+	default:
+		p.tok = gqlToken{kind: gqlPunct, value: string(c), pos: start}
+		p.fail("unexpected character '%c'", c)
+	}
+}
+
+func gqlLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func gqlDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (p *gqlParser) lexNumber() {
+	start := p.pos
+	kind := gqlInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	digits := func() {
+		begin := p.pos
+		for p.pos < len(p.src) && gqlDigit(p.src[p.pos]) {
+			p.pos++
+		}
+		if p.pos == begin {
+			p.tok = gqlToken{kind: gqlInt, value: p.src[start:p.pos], pos: start}
+			p.fail("invalid number")
+		}
+	}
+	intStart := p.pos
+	digits()
+	// 整数部分不能有前导0
+	if p.pos-intStart > 1 && p.src[intStart] == '0' {
+		p.tok = gqlToken{kind: gqlInt, value: p.src[start:p.pos], pos: start}
+		p.fail("invalid number")
+	}
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = gqlFloat
+		p.pos++
+		digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = gqlFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		digits()
+	}
+	p.tok = gqlToken{kind: kind, value: p.src[start:p.pos], pos: start}
+}
+
+// lexString 解析字符串, 块字符串去除公共缩进
+func (p *gqlParser) lexString() {
+	start := p.pos
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		end := strings.Index(p.src[p.pos+3:], `"""`)
+		if end < 0 {
+			p.tok = gqlToken{kind: gqlString, pos: start}
+			p.fail("unterminated string")
+		}
+		raw := strings.Replace(p.src[p.pos+3:p.pos+3+end], `\"""`, `"""`, -1)
+		p.pos += end + 6
+		p.tok = gqlToken{kind: gqlString, value: blockString(raw), pos: start}
+		return
+	}
+	p.pos++
+	var b strings.Builder
+	for {
+		if p.pos >= len(p.src) || p.src[p.pos] == '\n' || p.src[p.pos] == '\r' {
+			p.tok = gqlToken{kind: gqlString, pos: start}
+			p.fail("unterminated string")
+		}
+		c := p.src[p.pos]
+		if c == '"' {
+			p.pos++
+			break
+		}
+		if c != '\\' {
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+		if p.pos+1 >= len(p.src) {
+			p.fail("unterminated string")
+		}
+		esc := p.src[p.pos+1]
+		p.pos += 2
+		switch esc {
+		case '"', '\\', '/':
+			b.WriteByte(esc)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				p.fail("invalid unicode escape")
+			}
+			r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				p.fail("invalid unicode escape")
+			}
+			var buf [utf8.UTFMax]byte
+			b.Write(buf[:utf8.EncodeRune(buf[:], rune(r))])
+			p.pos += 4
+		default:
+			p.fail("invalid escape '\\%c'", esc)
+		}
+	}
+	p.tok = gqlToken{kind: gqlString, value: b.String(), pos: start}
+}
+
+// blockString 按GraphQL规范去除块字符串的公共缩进及首尾空行
+func blockString(raw string) string {
+	lines := strings.Split(strings.Replace(strings.Replace(raw, "\r\n", "\n", -1), "\r", "\n", -1), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = strings.TrimLeft(lines[i], " \t")
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,74 @@
+package restful
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseGraphQL(t *testing.T) {
+	doc, err := parseGraphQL("\ufeff# comment\n"+`query Q($id: [ID!]! = ["a"], $o: In) @d {
+		a: user(id: $id, n: -1.5e3, s: "xA\n", b: """
+			block "quoted"
+			  indented
+		""", e: ENUM, l: [1, true, null], o: {k: $o}) { name, ...F @include(if: true) ... on User { id } }
+	}
+	fragment F on User { name }
+	mutation { m }`, 0)
+	assert.NoError(t, err)
+	assert.Len(t, doc.operations, 2)
+	op := doc.operations[0]
+	assert.Equal(t, "query", op.kind)
+	assert.Equal(t, "Q", op.name)
+	assert.Equal(t, []gqlVariableDef{{name: "id", defaultValue: []interface{}{"a"}, hasDefault: true}, {name: "o"}}, op.variables)
+
+	sel := op.selections[0]
+	assert.Equal(t, "a", sel.alias)
+	assert.Equal(t, "user", sel.name)
+	assert.Equal(t, []gqlArgument{
+		{name: "id", value: gqlVariable("id")},
+		{name: "n", value: json.Number("-1.5e3")},
+		{name: "s", value: "xA\n"},
+		{name: "b", value: "block \"quoted\"\n  indented"},
+		{name: "e", value: gqlEnum("ENUM")},
+		{name: "l", value: []interface{}{json.Number("1"), true, nil}},
+		{name: "o", value: gqlObjectValue{{name: "k", value: gqlVariable("o")}}},
+	}, sel.arguments)
+	assert.Len(t, sel.selections, 3)
+	assert.Equal(t, "F", sel.selections[1].spread)
+	assert.Equal(t, "include", sel.selections[1].directives[0].name)
+	assert.True(t, sel.selections[2].inline)
+	assert.Equal(t, "name", doc.fragments["F"].selections[0].name)
+	assert.Equal(t, "mutation", doc.operations[1].kind)
+
+	for _, src := range []string{
+		"",
+		"{ }",
+		"{ a",
+		"{ a(b: $) }",
+		`{ a(b: "x) }`,
+		"{ a(b: 01) }",
+		"fragment on on User { a }",
+		"{ a } { b } x",
+		"query Q($a: Int = $b) { a }",
+	} {
+		_, err := parseGraphQL(src, 0)
+		assert.Error(t, err, src)
+	}
+
+	// 选择集、列表及对象值的嵌套深度
+	_, err = parseGraphQL("{ a { b } }", 2)
+	assert.NoError(t, err)
+	for _, src := range []string{
+		"{ a { b { c } } }",
+		"{ a(l: [[1]]) }",
+		"{ a(o: {k: {k: 1}}) }",
+		"query($v: [[[Int]]]) { a }",
+		strings.Repeat("{ a ", 100000) + strings.Repeat("}", 100000),
+	} {
+		_, err := parseGraphQL(src, 2)
+		assert.Error(t, err, src)
+	}
+}
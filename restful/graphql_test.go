@@ -0,0 +1,322 @@
+package restful
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// gqlGreeter 模拟生成的HttpHandler及GraphQLSchema, 字段通过rpc路由解析
+type gqlGreeter struct {
+	mu      sync.Mutex
+	renamed []string
+	// 同时执行的slow字段数
+	active, peak int32
+}
+
+func (h *gqlGreeter) URLPatterns() []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/field", Version: "v1", ResourceFuncName: "Field",
+			Metadata: map[string]string{RPC_METHOD_METADATA: "/gqltest.Greeter/Field"}},
+		{Method: http.MethodGet, Path: "/message", Version: "v1", ResourceFuncName: "Message",
+			Metadata: map[string]string{RPC_METHOD_METADATA: "/gqltest.Greeter/Message"}},
+		{Method: http.MethodPost, Path: "/rename", Version: "v1", ResourceFuncName: "Rename",
+			Metadata: map[string]string{RPC_METHOD_METADATA: "/gqltest.Greeter/Rename"}},
+	}
+}
+
+func (h *gqlGreeter) GraphQLSchema() GraphQLSchema {
+	return GraphQLSchema{
+		Types: map[string]string{
+			"FieldDescriptorProto": "type FieldDescriptorProto {\n  name: String\n  number: Int\n}",
+		},
+		Fields: []GraphQLField{
+			{Operation: GraphQLQuery, Name: "field", Definition: "field(name: String, number: Int): FieldDescriptorProto", RPCMethod: "/gqltest.Greeter/Field"},
+			{Operation: GraphQLQuery, Name: "message", Definition: "message(name: String): DescriptorProto", RPCMethod: "/gqltest.Greeter/Message"},
+			{Operation: GraphQLMutation, Name: "rename", Definition: "rename(name: String): FieldDescriptorProto", RPCMethod: "/gqltest.Greeter/Rename"},
+		},
+	}
+}
+
+func (h *gqlGreeter) Field(ctx *Context) {
+	var req descriptorpb.FieldDescriptorProto
+	if err := ctx.Read(&req); err != nil {
+		Response(ctx, nil, err)
+		return
+	}
+	switch req.GetName() {
+	case "":
+		Response(ctx, nil, status.Errorf(codes.NotFound, "(%d)not found", 10001))
+		return
+	case "nil":
+		var resp *descriptorpb.FieldDescriptorProto
+		Response(ctx, resp, nil)
+		return
+	case "slow":
+		active := atomic.AddInt32(&h.active, 1)
+		defer atomic.AddInt32(&h.active, -1)
+		h.mu.Lock()
+		if active > h.peak {
+			h.peak = active
+		}
+		h.mu.Unlock()
+		time.Sleep(5 * time.Millisecond)
+	}
+	req.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	req.Options = &descriptorpb.FieldOptions{Packed: proto.Bool(true)}
+	req.JsonName = proto.String(ctx.Req.Request.Header.Get("X-Trace"))
+	Response(ctx, &req, nil)
+}
+
+func (h *gqlGreeter) Message(ctx *Context) {
+	var req descriptorpb.DescriptorProto
+	if err := ctx.Read(&req); err != nil {
+		Response(ctx, nil, err)
+		return
+	}
+	req.Field = []*descriptorpb.FieldDescriptorProto{{Name: proto.String("a")}, {Name: proto.String("b")}}
+	Response(ctx, &req, nil)
+}
+
+func (h *gqlGreeter) Rename(ctx *Context) {
+	var req descriptorpb.FieldDescriptorProto
+	if err := ctx.Read(&req); err != nil {
+		Response(ctx, nil, err)
+		return
+	}
+	h.mu.Lock()
+	h.renamed = append(h.renamed, req.GetName())
+	h.mu.Unlock()
+	Response(ctx, &req, nil)
+}
+
+func gqlMux(t *testing.T, h *gqlGreeter) *Mux {
+	initLogger()
+	m, err := NewMux(h)
+	assert.NoError(t, err)
+	assert.NoError(t, m.HandleGraphQL("/graphql", 0, 0))
+	return m
+}
+
+func gqlDo(m http.Handler, req *http.Request) (int, string) {
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, req)
+	body, _ := ioutil.ReadAll(rw.Body)
+	// 按字段顺序比较, 去掉缩进
+	var b bytes.Buffer
+	if json.Compact(&b, body) == nil {
+		return rw.Code, b.String()
+	}
+	return rw.Code, strings.TrimSpace(string(body))
+}
+
+func gqlPost(m http.Handler, body string) (int, string) {
+	req := httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(body))
+	req.Header.Set(Header_content_type, "application/json")
+	return gqlDo(m, req)
+}
+
+func TestGraphQL(t *testing.T) {
+	m := gqlMux(t, &gqlGreeter{})
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"query": `query Q($n: Int = 3, $skip: Boolean!) {
+			__typename
+			f: field(name: "x", number: $n) { ...F label options { packed } __typename }
+			field(name: "y") @skip(if: $skip) { name }
+			message(name: "m") { name field { name } }
+		}
+		fragment F on FieldDescriptorProto { number name ... on FieldDescriptorProto { name } }`,
+		"variables": map[string]interface{}{"skip": true},
+	})
+	code, out := gqlPost(m, string(body))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"data":{"__typename":"Query","f":{"number":3,"name":"x","label":"LABEL_REPEATED","options":{"packed":true},"__typename":"FieldDescriptorProto"},"message":{"name":"m","field":[{"name":"a"},{"name":"b"}]}}}`, out)
+
+	// 头域传递到解析函数
+	req := httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`{ field(name: "x") { json_name } }`), nil)
+	req.Header.Set("X-Trace", "t1")
+	code, out = gqlDo(m, req)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"data":{"field":{"json_name":"t1"}}}`, out)
+
+	req = httptest.NewRequest(http.MethodPost, "/graphql", strings.NewReader(`{ a: field(name: "nil") { name } }`))
+	req.Header.Set(Header_content_type, "application/graphql")
+	code, out = gqlDo(m, req)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"data":{"a":null}}`, out)
+}
+
+func TestGraphQLFieldErrors(t *testing.T) {
+	m := gqlMux(t, &gqlGreeter{})
+
+	code, out := gqlPost(m, `{"query":"{ a: field { name } b: field(name: \"x\") { name oops options } c: field(name: \"x\") { name { x } } }"}`)
+	assert.Equal(t, http.StatusOK, code)
+	var resp map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(out), &resp))
+	assert.Equal(t, map[string]interface{}{
+		"a": nil,
+		"b": map[string]interface{}{"name": "x", "oops": nil, "options": nil},
+		"c": map[string]interface{}{"name": nil},
+	}, resp["data"])
+	errs := resp["errors"].([]interface{})
+	assert.Len(t, errs, 4)
+	assert.Contains(t, errs, map[string]interface{}{
+		"message": "(10001)not found", "path": []interface{}{"a"},
+		"extensions": map[string]interface{}{"code": float64(codes.NotFound), "err_code": float64(10001)},
+	})
+	var paths []interface{}
+	for _, e := range errs {
+		paths = append(paths, e.(map[string]interface{})["path"])
+	}
+	assert.Contains(t, paths, []interface{}{"b", "oops"})
+	assert.Contains(t, paths, []interface{}{"b", "options"})
+	assert.Contains(t, paths, []interface{}{"c", "name"})
+
+	// 无效的参数
+	_, out = gqlPost(m, `{"query":"{ field(name: 1) { name } }"}`)
+	assert.Contains(t, out, `"err_code":10411`)
+	assert.Contains(t, out, `"data":{"field":null}`)
+}
+
+func TestGraphQLMutation(t *testing.T) {
+	h := &gqlGreeter{}
+	m := gqlMux(t, h)
+
+	code, out := gqlPost(m, `{"query":"mutation { a: rename(name: \"1\") { name } b: rename(name: \"2\") { name } c: rename(name: \"3\") { name } }"}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `{"data":{"a":{"name":"1"},"b":{"name":"2"},"c":{"name":"3"}}}`, out)
+	// 变更依次执行
+	assert.Equal(t, []string{"1", "2", "3"}, h.renamed)
+}
+
+func TestGraphQLInvalid(t *testing.T) {
+	m := gqlMux(t, &gqlGreeter{})
+
+	for _, body := range []string{
+		`{"query":`,
+		`{"query":""}`,
+		`{"query":"{ field(name: \"x\") { name }"}`,
+		`{"query":"{ nothing }"}`,
+		`{"query":"query A { __typename } query B { __typename }"}`,
+		`{"query":"query A { __typename }","operationName":"B"}`,
+		`{"query":"subscription { __typename }"}`,
+		`{"query":"{ ...F }"}`,
+		`{"query":"{ ...F } fragment F on Query { ...F }"}`,
+		`{"query":"{ a: field(name: \"x\") { name } a: message { name } }"}`,
+	} {
+		code, out := gqlPost(m, body)
+		assert.Equal(t, http.StatusBadRequest, code, body)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(out), &resp))
+		assert.Equal(t, float64(INVALID_GRAPHQL_BODY_ERR), resp["err_code"], body)
+	}
+
+	// GET不能执行变更
+	code, out := gqlDo(m, httptest.NewRequest(http.MethodGet, "/graphql?query="+url.QueryEscape(`mutation { rename { name } }`), nil))
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, out, "mutation must use POST")
+
+	m.BodyLimit = 8
+	assert.NoError(t, m.HandleGraphQL("/graphql2", 0, 0))
+	req := httptest.NewRequest(http.MethodPost, "/graphql2", strings.NewReader(`{"query":"{ __typename }"}`))
+	code, _ = gqlDo(m, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, code)
+}
+
+func TestGraphQLLimits(t *testing.T) {
+	h := &gqlGreeter{}
+	m := gqlMux(t, h)
+	assert.NoError(t, m.HandleGraphQL("/limited", 3, 2))
+	limited := func(query string) (int, string) {
+		body, _ := json.Marshal(map[string]string{"query": query})
+		req := httptest.NewRequest(http.MethodPost, "/limited", bytes.NewReader(body))
+		req.Header.Set(Header_content_type, "application/json")
+		return gqlDo(m, req)
+	}
+
+	code, _ := limited(`{ a: field(name: "x") { name } }`)
+	assert.Equal(t, http.StatusOK, code)
+	for query, msg := range map[string]string{
+		// 别名重复同一字段
+		`{ a: field(name: "x") { name } b: field(name: "x") { name } }`: "max fields 3",
+		// 解析时的嵌套
+		`{ message(name: "m") { field { name } } }`: "max depth 2",
+		// 片段展开后的嵌套
+		`{ message(name: "m") { ...F } } fragment F on DescriptorProto { field { name } }`: "max depth 2",
+		// 递归引用的片段
+		`{ message(name: "m") { ...F } } fragment F on DescriptorProto { field { ...F } }`: "max depth 2",
+	} {
+		code, out := limited(query)
+		assert.Equal(t, http.StatusBadRequest, code, query)
+		assert.Contains(t, out, `"err_code":10411`, query)
+		assert.Contains(t, out, msg, query)
+	}
+
+	// 顶层查询字段由有限的协程执行
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i < 3*DefaultGraphQLConcurrency; i++ {
+		fmt.Fprintf(&b, ` f%d: field(name: "slow") { name }`, i)
+	}
+	b.WriteString(" }")
+	code, out := gqlPost(m, `{"query":`+strconv.Quote(b.String())+`}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.NotContains(t, out, "errors")
+	assert.True(t, h.peak > 1)
+	assert.True(t, h.peak <= DefaultGraphQLConcurrency)
+}
+
+func TestGraphQLSchemaSDL(t *testing.T) {
+	m := gqlMux(t, &gqlGreeter{})
+	code, out := gqlDo(m, httptest.NewRequest(http.MethodGet, "/graphql/schema", nil))
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, `scalar JSON
+
+type Query {
+  field(name: String, number: Int): FieldDescriptorProto
+  message(name: String): DescriptorProto
+}
+
+type Mutation {
+  rename(name: String): FieldDescriptorProto
+}
+
+type FieldDescriptorProto {
+  name: String
+  number: Int
+}`, out)
+
+	// 同名字段不重复注册, 停用的方法对应的字段不加入
+	entries := make([]routeEntry, 0)
+	for _, route := range withRPCRoutes(new(gqlGreeter).URLPatterns()) {
+		entries = append(entries, routeEntry{route: route})
+	}
+	schema := new(gqlGreeter).GraphQLSchema()
+	fields := newGraphQLFields([]GraphQLSchema{schema, schema}, jsonRPCMethods(entries, nil))
+	assert.Len(t, fields.query, 2)
+	assert.Len(t, fields.mutation, 1)
+	fields = newGraphQLFields([]GraphQLSchema{schema}, jsonRPCMethods(entries, map[string]bool{
+		routeKey("", "/gqltest.Greeter/Field", http.MethodPost): true,
+	}))
+	assert.Len(t, fields.query, 1)
+	_, ok := fields.field(GraphQLQuery, "field")
+	assert.False(t, ok)
+	assert.NotContains(t, fields.sdl(), "field(name")
+}
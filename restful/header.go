@@ -3,11 +3,13 @@ package restful
 import (
 	"crypto/md5"
 	"encoding/hex"
+	"net"
+	"net/http"
 	"net/url"
 	"strings"
 
-	"github.com/go-chassis/go-chassis/core/lager"
 	"github.com/go-chassis/go-chassis/pkg/runtime"
+	"github.com/go-mesh/openlogging"
 )
 
 const (
@@ -39,6 +41,27 @@ const (
 	Header_referer           = "referer"
 	Header_content_type      = "content-type"
 	Header_method            = "paasport-request-method"
+	Header_grpc_timeout      = "grpc-timeout"
+	Header_x_request_timeout = "x-request-timeout"
+
+	Header_retry_after         = "retry-after"
+	Header_ratelimit_limit     = "ratelimit-limit"
+	Header_ratelimit_remaining = "ratelimit-remaining"
+	Header_ratelimit_reset     = "ratelimit-reset"
+	Header_vary                = "vary"
+	Header_accept_encoding     = "accept-encoding"
+	Header_content_encoding    = "content-encoding"
+	Header_content_length      = "content-length"
+	Header_etag                = "etag"
+	Header_if_none_match       = "if-none-match"
+	Header_if_match            = "if-match"
+	Header_if_unmodified_since = "if-unmodified-since"
+
+	Header_grpc_status              = "grpc-status"
+	Header_grpc_message             = "grpc-message"
+	Header_grpc_encoding            = "grpc-encoding"
+	Header_connect_timeout          = "connect-timeout-ms"
+	Header_connect_content_encoding = "connect-content-encoding"
 )
 
 // 可通过的头域列表
@@ -94,7 +117,7 @@ func IncommingHeaderMatcher(h string) bool {
 }
 
 func IncommingHeader(ctx *Context) map[string]string {
-	lager.Logger.Debugf("incomming headers: %v", ctx.Req.Request.Header)
+	openlogging.GetLogger().Debugf("incomming headers: %v", requestRedactor(ctx.Req).lazyHeader(ctx.Req.Request.Header))
 	var header = make(map[string]string)
 	for key := range ctx.Req.Request.Header {
 		if IncommingHeaderMatcher(key) {
@@ -141,9 +164,31 @@ func IncommingHeader(ctx *Context) map[string]string {
 	// }
 	// 注入当前项目APPID
 	header[Header_project_name] = runtime.App
+	// 写请求的条件头域透传给gRPC实现
+	if isConditionalWrite(ctx.Req.Request.Method) {
+		for _, h := range preconditionHeaders {
+			if v := ctx.ReadHeader(h); v != "" {
+				header[h] = v
+			}
+		}
+	}
 	return header
 }
 
+// ClientIP 获取客户端IP, 优先使用x-forwarded-for中的第一个地址
+// 该地址可由客户端伪造, 只用于记录日志, 不能用于访问控制或限流
+func ClientIP(req *http.Request) string {
+	if forwarded := req.Header.Get(Header_x_forwarded_for); forwarded != "" {
+		if ip := strings.TrimSpace(strings.Split(forwarded, ",")[0]); ip != "" {
+			return ip
+		}
+	}
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err == nil {
+		return host
+	}
+	return req.RemoteAddr
+}
+
 func Md5(data string) string {
 	hash := md5.New()
 	hash.Write([]byte(data))
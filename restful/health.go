@@ -0,0 +1,219 @@
+package restful
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-mesh/openlogging"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// 健康检查接口配置
+const (
+	HealthConfigPrefix   = "cse.restful.health"
+	HealthEnableKey      = HealthConfigPrefix + ".enable"        // 是否开启健康检查接口, 默认开启
+	HealthLivenessKey    = HealthConfigPrefix + ".livenessPath"  // 存活检查路径
+	HealthReadinessKey   = HealthConfigPrefix + ".readinessPath" // 就绪检查路径
+	HealthTimeoutKey     = HealthConfigPrefix + ".timeout"       // 单个就绪检查的超时时间, 如 1s
+	DefaultLivenessPath  = "/healthz"
+	DefaultReadinessPath = "/readyz"
+	defaultHealthTimeout = time.Second
+	healthVerboseParam   = "verbose"
+)
+
+// 健康检查结果
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// HealthChecker 就绪检查, 返回错误时服务未就绪
+type HealthChecker func(ctx context.Context) error
+
+// GrpcHealth grpc.health.v1.Health服务的检查接口
+type GrpcHealth interface {
+	Check(context.Context, *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error)
+}
+
+// GrpcHealthChecker 使用grpc.health.v1.Health检查服务, 服务名为空时检查整体状态
+func GrpcHealthChecker(h GrpcHealth, service string) HealthChecker {
+	return func(ctx context.Context) error {
+		resp, err := h.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			return err
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("service '%s' is %s", service, resp.GetStatus())
+		}
+		return nil
+	}
+}
+
+// health 就绪检查
+type health struct {
+	mu       sync.RWMutex
+	timeout  time.Duration
+	checkers map[string]HealthChecker
+}
+
+func newHealth(timeout time.Duration) *health {
+	return &health{timeout: timeout, checkers: make(map[string]HealthChecker)}
+}
+
+// healthCheck 单个检查的结果
+type healthCheck struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// healthReport 健康检查接口的json格式
+type healthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]healthCheck `json:"checks,omitempty"`
+}
+
+// check 并发执行所有就绪检查
+func (h *health) check(ctx context.Context) map[string]healthCheck {
+	h.mu.RLock()
+	checkers := make(map[string]HealthChecker, len(h.checkers))
+	for name, checker := range h.checkers {
+		checkers[name] = checker
+	}
+	h.mu.RUnlock()
+
+	results := make(map[string]healthCheck, len(checkers))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, checker := range checkers {
+		wg.Add(1)
+		go func(name string, checker HealthChecker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, h.timeout)
+			defer cancel()
+			result := healthCheck{Status: HealthStatusOK}
+			if err := runChecker(ctx, checker); err != nil {
+				result = healthCheck{Status: HealthStatusFail, Error: err.Error()}
+			}
+			mu.Lock()
+			results[name] = result
+			mu.Unlock()
+		}(name, checker)
+	}
+	wg.Wait()
+	return results
+}
+
+// runChecker 执行检查, 超时或panic时返回错误
+func runChecker(ctx context.Context, checker HealthChecker) (err error) {
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if e := recover(); e != nil {
+				done <- fmt.Errorf("checker panic: %v", e)
+			}
+		}()
+		done <- checker(ctx)
+	}()
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// AddReadinessChecker 添加就绪检查, 同名的检查被替换
+func (r *RestfulServer) AddReadinessChecker(name string, checker HealthChecker) {
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+	r.health.checkers[name] = checker
+}
+
+// RemoveReadinessChecker 删除就绪检查
+func (r *RestfulServer) RemoveReadinessChecker(name string) {
+	r.health.mu.Lock()
+	defer r.health.mu.Unlock()
+	delete(r.health.checkers, name)
+}
+
+// writeHealth 探针请求只返回状态码及ok或fail, 携带verbose参数时返回json详情
+func writeHealth(req *restful.Request, rep *restful.Response, report healthReport) {
+	code := http.StatusOK
+	if report.Status != HealthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	rep.AddHeader("Cache-Control", "no-store")
+	if _, ok := req.Request.URL.Query()[healthVerboseParam]; ok {
+		rep.WriteHeaderAndJson(code, report, "application/json;charset=utf-8")
+		return
+	}
+	rep.AddHeader(Header_content_type, "text/plain;charset=utf-8")
+	rep.WriteHeader(code)
+	rep.Write([]byte(report.Status))
+}
+
+// handleLiveness 进程存活即返回ok
+func (r *RestfulServer) handleLiveness(req *restful.Request, rep *restful.Response) {
+	writeHealth(req, rep, healthReport{Status: HealthStatusOK})
+}
+
+// handleReadiness 服务已启动未停止且所有就绪检查通过时返回ok
+func (r *RestfulServer) handleReadiness(req *restful.Request, rep *restful.Response) {
+	report := healthReport{Status: HealthStatusOK, Checks: r.health.check(req.Request.Context())}
+	server := healthCheck{Status: HealthStatusOK}
+	if !r.Ready() {
+		server = healthCheck{Status: HealthStatusFail, Error: "server not started or stopping"}
+	}
+	report.Checks["server"] = server
+	var failed []string
+	for name, check := range report.Checks {
+		if check.Status != HealthStatusOK {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		report.Status = HealthStatusFail
+		openlogging.GetLogger().Warnf("readiness check failed: %s", strings.Join(failed, ","))
+	}
+	writeHealth(req, rep, report)
+}
+
+// healthRoutes 根据配置生成健康检查路由, 未开启时为空
+func (r *RestfulServer) healthRoutes() []routeEntry {
+	if !archaius.GetBool(HealthEnableKey, true) {
+		return nil
+	}
+	if v := archaius.GetString(HealthTimeoutKey, ""); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			r.health.timeout = d
+		} else {
+			openlogging.GetLogger().Warnf("invalid health timeout '%s'", v)
+		}
+	}
+	return r.healthEntries(archaius.GetString(HealthLivenessKey, DefaultLivenessPath),
+		archaius.GetString(HealthReadinessKey, DefaultReadinessPath))
+}
+
+// healthEntries 存活及就绪检查路由
+func (r *RestfulServer) healthEntries(liveness, readiness string) []routeEntry {
+	var entries []routeEntry
+	for path, handler := range map[string]restful.RouteFunction{
+		liveness:  r.handleLiveness,
+		readiness: r.handleReadiness,
+	} {
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		openlogging.Info("Enabled health API on " + path)
+		entries = append(entries, routeEntry{route: Route{Method: http.MethodGet, Path: path}, handler: handler})
+	}
+	return entries
+}
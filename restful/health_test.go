@@ -0,0 +1,82 @@
+package restful
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+func newHealthServer(t *testing.T) *RestfulServer {
+	initLogger()
+	r := &RestfulServer{
+		routes:    newRouteTable(),
+		lifecycle: newLifecycle(),
+		health:    newHealth(50 * time.Millisecond),
+	}
+	r.system = r.healthEntries(DefaultLivenessPath, DefaultReadinessPath)
+	assert.NoError(t, r.apply(nil))
+	return r
+}
+
+func getHealth(r *RestfulServer, target string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestLiveness(t *testing.T) {
+	r := newHealthServer(t)
+	rw := getHealth(r, DefaultLivenessPath)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, HealthStatusOK, rw.Body.String())
+}
+
+func TestReadiness(t *testing.T) {
+	r := newHealthServer(t)
+	// 未启动时未就绪
+	assert.Equal(t, http.StatusServiceUnavailable, getHealth(r, DefaultReadinessPath).Code)
+	atomic.StoreInt32(&r.lifecycle.ready, 1)
+	rw := getHealth(r, DefaultReadinessPath)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, HealthStatusOK, rw.Body.String())
+
+	r.AddReadinessChecker("db", func(ctx context.Context) error { return errors.New("connection refused") })
+	r.AddReadinessChecker("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+	rw = getHealth(r, DefaultReadinessPath+"?verbose")
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	var report healthReport
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &report))
+	assert.Equal(t, HealthStatusFail, report.Status)
+	assert.Equal(t, healthCheck{Status: HealthStatusFail, Error: "connection refused"}, report.Checks["db"])
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["slow"].Error)
+	assert.Equal(t, HealthStatusOK, report.Checks["server"].Status)
+
+	r.RemoveReadinessChecker("db")
+	r.RemoveReadinessChecker("slow")
+	assert.Equal(t, http.StatusOK, getHealth(r, DefaultReadinessPath).Code)
+}
+
+func TestGrpcHealthChecker(t *testing.T) {
+	r := newHealthServer(t)
+	atomic.StoreInt32(&r.lifecycle.ready, 1)
+	hs := grpchealth.NewServer()
+	r.AddReadinessChecker("grpc", GrpcHealthChecker(hs, "helloworld.Greeter"))
+	assert.Equal(t, http.StatusServiceUnavailable, getHealth(r, DefaultReadinessPath).Code)
+	hs.SetServingStatus("helloworld.Greeter", healthpb.HealthCheckResponse_SERVING)
+	assert.Equal(t, http.StatusOK, getHealth(r, DefaultReadinessPath).Code)
+	hs.SetServingStatus("helloworld.Greeter", healthpb.HealthCheckResponse_NOT_SERVING)
+	assert.Equal(t, http.StatusServiceUnavailable, getHealth(r, DefaultReadinessPath).Code)
+}
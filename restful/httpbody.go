@@ -0,0 +1,200 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-mesh/openlogging"
+	"github.com/golang/protobuf/ptypes"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// ReadHttpBody 将原始请求体读取到HttpBody中, gRPC-Web及Connect请求按协议解码
+func (bs *Context) ReadHttpBody(body *httpbody.HttpBody) error {
+	if bs.rpc != nil {
+		return bs.rpc.read(bs, body)
+	}
+	body.ContentType = bs.ReadHeader(Header_content_type)
+	if bs.Req.Request.Body == nil {
+		return nil
+	}
+	data, err := ioutil.ReadAll(bs.Req.Request.Body)
+	if err != nil {
+		return err
+	}
+	body.Data = data
+	return nil
+}
+
+// httpBodyHeaders 将HttpBody的内容类型及扩展写入头域
+// 扩展中的google.protobuf.Struct作为头域, 字段值为字符串、数字或布尔
+func httpBodyHeaders(header http.Header, body *httpbody.HttpBody) {
+	contentType := body.GetContentType()
+	if contentType == "" {
+		contentType = MimeFile
+	}
+	header.Set(Header_content_type, contentType)
+	for _, ext := range body.GetExtensions() {
+		var s structpb.Struct
+		if !ptypes.Is(ext, &s) {
+			continue
+		}
+		if err := ptypes.UnmarshalAny(ext, &s); err != nil {
+			openlogging.GetLogger().Warnf("unmarshal http body extension failed: %s", err.Error())
+			continue
+		}
+		for k, v := range s.GetFields() {
+			switch kind := v.GetKind().(type) {
+			case *structpb.Value_StringValue:
+				header.Set(k, kind.StringValue)
+			case *structpb.Value_NumberValue:
+				header.Set(k, strconv.FormatFloat(kind.NumberValue, 'f', -1, 64))
+			case *structpb.Value_BoolValue:
+				header.Set(k, strconv.FormatBool(kind.BoolValue))
+			}
+		}
+	}
+}
+
+// ResponseHttpBody 以HttpBody的内容类型直接响应data, GET及HEAD请求支持Range
+// gRPC-Web及Connect请求以HttpBody消息响应
+func ResponseHttpBody(b *Context, resp *httpbody.HttpBody, err error) {
+	if err != nil || resp == nil {
+		Response(b, nil, err)
+		return
+	}
+	if b.rpc != nil {
+		Response(b, resp, nil)
+		return
+	}
+	traceResponse(b.Ctx, codes.OK, 0)
+	setResult(b.Req, codes.OK, 0)
+	httpBodyHeaders(b.Resp.Header(), resp)
+	switch b.ReadRequest().Method {
+	case http.MethodGet, http.MethodHead:
+		http.ServeContent(b.Resp, b.ReadRequest(), "", time.Time{}, bytes.NewReader(resp.GetData()))
+	default:
+		b.Resp.Header().Set(Header_content_length, strconv.Itoa(len(resp.GetData())))
+		b.WriteHeader(http.StatusOK)
+		b.Write(resp.GetData())
+	}
+}
+
+// HttpBodyStream 以分块传输响应服务端流式返回的HttpBody
+// 实现了生成代码中 <Service>_<Method>Server 接口
+type HttpBodyStream struct {
+	ctx    *Context
+	header metadata.MD
+	sent   bool
+}
+
+// NewHttpBodyStream 新建HttpBody流式响应
+func NewHttpBodyStream(ctx *Context) *HttpBodyStream {
+	return &HttpBodyStream{ctx: ctx, header: metadata.MD{}}
+}
+
+// Send 发送一个HttpBody, 内容类型及扩展头域以第一个为准
+// gRPC-Web及Connect请求每个HttpBody作为一个消息帧发送
+func (s *HttpBodyStream) Send(body *httpbody.HttpBody) error {
+	if s.ctx.rpc != nil {
+		if !s.sent {
+			s.writeHeader()
+		}
+		return s.ctx.rpc.send(s.ctx, body)
+	}
+	if !s.sent {
+		httpBodyHeaders(s.ctx.Resp.Header(), body)
+		s.writeHeader()
+	}
+	if _, err := s.ctx.Resp.Write(body.GetData()); err != nil {
+		return err
+	}
+	s.ctx.Resp.Flush()
+	return s.ctx.Ctx.Err()
+}
+
+func (s *HttpBodyStream) writeHeader() {
+	for k, v := range s.header {
+		for _, value := range v {
+			s.ctx.Resp.AddHeader(k, value)
+		}
+	}
+	if s.ctx.rpc != nil {
+		s.ctx.rpc.start(s.ctx)
+		s.sent = true
+		return
+	}
+	traceResponse(s.ctx.Ctx, codes.OK, 0)
+	setResult(s.ctx.Req, codes.OK, 0)
+	s.ctx.WriteHeader(http.StatusOK)
+	s.sent = true
+}
+
+// SetHeader 设置响应头域, 需在第一次发送之前调用
+func (s *HttpBodyStream) SetHeader(md metadata.MD) error {
+	if s.sent {
+		return errors.New("http body stream header already sent")
+	}
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+// SendHeader 立即发送响应头域
+func (s *HttpBodyStream) SendHeader(md metadata.MD) error {
+	if err := s.SetHeader(md); err != nil {
+		return err
+	}
+	s.writeHeader()
+	return nil
+}
+
+// SetTrailer http响应不支持trailer, 忽略
+func (s *HttpBodyStream) SetTrailer(md metadata.MD) {}
+
+// Context 请求的上下文
+func (s *HttpBodyStream) Context() context.Context {
+	return s.ctx.Ctx
+}
+
+// SendMsg 发送HttpBody类型的消息
+func (s *HttpBodyStream) SendMsg(m interface{}) error {
+	body, ok := m.(*httpbody.HttpBody)
+	if !ok {
+		return fmt.Errorf("http body stream can not send %T", m)
+	}
+	return s.Send(body)
+}
+
+// RecvMsg 请求消息已经读取, 没有更多消息
+func (s *HttpBodyStream) RecvMsg(m interface{}) error {
+	return io.EOF
+}
+
+// Close 结束流式响应, 未发送任何内容时以错误响应
+func (s *HttpBodyStream) Close(err error) {
+	if s.ctx.rpc != nil {
+		s.ctx.rpc.finish(s.ctx, err)
+		return
+	}
+	if !s.sent {
+		if err == nil {
+			s.writeHeader()
+			return
+		}
+		Response(s.ctx, nil, err)
+		return
+	}
+	if err != nil {
+		openlogging.GetLogger().Errorf("http body stream on '%s' aborted: %s", s.ctx.ReadRequest().URL.String(), err.Error())
+	}
+}
@@ -0,0 +1,90 @@
+package restful
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func newHttpBodyContext(method, target string, header http.Header, body string) (*Context, *httptest.ResponseRecorder) {
+	r, _ := http.NewRequest(method, target, strings.NewReader(body))
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	rw := httptest.NewRecorder()
+	bs := NewBaseServer(context.TODO())
+	bs.Req = restful.NewRequest(r)
+	bs.Resp = restful.NewResponse(rw)
+	return bs, rw
+}
+
+func TestResponseHttpBody(t *testing.T) {
+	initLogger()
+	ext, err := ptypes.MarshalAny(&structpb.Struct{Fields: map[string]*structpb.Value{
+		"content-disposition": {Kind: &structpb.Value_StringValue{StringValue: `attachment; filename="report.csv"`}},
+		"x-total":             {Kind: &structpb.Value_NumberValue{NumberValue: 3}},
+	}})
+	assert.NoError(t, err)
+	body := &httpbody.HttpBody{
+		ContentType: "text/csv",
+		Data:        []byte("id,name\n1,a\n2,b\n"),
+		Extensions:  []*anypb.Any{ext},
+	}
+
+	bs, rw := newHttpBodyContext(http.MethodGet, "/report", nil, "")
+	ResponseHttpBody(bs, body, nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "text/csv", rw.Header().Get(Header_content_type))
+	assert.Equal(t, `attachment; filename="report.csv"`, rw.Header().Get("Content-Disposition"))
+	assert.Equal(t, "3", rw.Header().Get("X-Total"))
+	assert.Equal(t, string(body.Data), rw.Body.String())
+
+	bs, rw = newHttpBodyContext(http.MethodGet, "/report", http.Header{"Range": {"bytes=8-10"}}, "")
+	ResponseHttpBody(bs, body, nil)
+	assert.Equal(t, http.StatusPartialContent, rw.Code)
+	assert.Equal(t, "bytes 8-10/16", rw.Header().Get("Content-Range"))
+	assert.Equal(t, "1,a", rw.Body.String())
+
+	bs, rw = newHttpBodyContext(http.MethodGet, "/report", nil, "")
+	ResponseHttpBody(bs, nil, errors.New("(10401)internal server error"))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+	assert.Contains(t, rw.Body.String(), "10401")
+}
+
+func TestReadHttpBody(t *testing.T) {
+	bs, _ := newHttpBodyContext(http.MethodPost, "/upload", http.Header{"Content-Type": {"image/png"}}, "\x89PNG")
+	var body httpbody.HttpBody
+	assert.NoError(t, bs.ReadHttpBody(&body))
+	assert.Equal(t, "image/png", body.ContentType)
+	assert.Equal(t, "\x89PNG", string(body.Data))
+}
+
+func TestHttpBodyStream(t *testing.T) {
+	initLogger()
+	bs, rw := newHttpBodyContext(http.MethodGet, "/download", nil, "")
+	stream := NewHttpBodyStream(bs)
+	assert.NoError(t, stream.SetHeader(metadata.Pairs("x-file-name", "a.txt")))
+	assert.NoError(t, stream.Send(&httpbody.HttpBody{ContentType: "text/plain", Data: []byte("hello ")}))
+	assert.NoError(t, stream.SendMsg(&httpbody.HttpBody{Data: []byte("world")}))
+	assert.Error(t, stream.SetHeader(metadata.Pairs("x-late", "1")))
+	stream.Close(nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "text/plain", rw.Header().Get(Header_content_type))
+	assert.Equal(t, "a.txt", rw.Header().Get("X-File-Name"))
+	assert.Equal(t, "hello world", rw.Body.String())
+
+	bs, rw = newHttpBodyContext(http.MethodGet, "/download", nil, "")
+	NewHttpBodyStream(bs).Close(ErrInternal)
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+}
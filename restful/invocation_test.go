@@ -0,0 +1,34 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-chassis/core/common"
+	"github.com/stretchr/testify/assert"
+)
+
+type ctxTestKey struct{}
+
+func TestHTTPRequest2InvocationContext(t *testing.T) {
+	parent := common.NewContext(map[string]string{"from-outer": "1", Header_app_id: "outer"})
+	parent = context.WithValue(parent, ctxTestKey{}, "span")
+	parent, cancel := context.WithCancel(parent)
+
+	r, _ := http.NewRequest(http.MethodGet, "/hello", nil)
+	r.Header.Set(Header_app_id, "app")
+	req := restful.NewRequest(r.WithContext(parent))
+
+	inv, err := HTTPRequest2Invocation(req, "schema", "Hello")
+	assert.NoError(t, err)
+	assert.Equal(t, "span", inv.Ctx.Value(ctxTestKey{}))
+
+	headers := common.FromContext(inv.Ctx)
+	assert.Equal(t, "1", headers["from-outer"])
+	assert.Equal(t, "app", headers[http.CanonicalHeaderKey(Header_app_id)])
+
+	cancel()
+	assert.Equal(t, context.Canceled, inv.Ctx.Err())
+}
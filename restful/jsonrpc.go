@@ -0,0 +1,229 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-mesh/openlogging"
+	"google.golang.org/grpc/status"
+)
+
+// JSON-RPC接口配置, 默认关闭
+const (
+	JSONRPCEnableKey       = "cse.restful.jsonrpc.enable"
+	JSONRPCPathKey         = "cse.restful.jsonrpc.path"
+	JSONRPCMaxBatchKey     = "cse.restful.jsonrpc.maxBatch" // 批量请求的最大调用数
+	DefaultJSONRPCPath     = "/rpc"
+	DefaultJSONRPCMaxBatch = 100
+)
+
+// JSON-RPC 2.0 错误码
+const (
+	JSONRPC_PARSE_ERR            = -32700 // 无效的json
+	JSONRPC_INVALID_REQUEST_ERR  = -32600 // 无效的请求对象
+	JSONRPC_METHOD_NOT_FOUND_ERR = -32601 // 方法不存在
+	JSONRPC_INVALID_PARAMS_ERR   = -32602 // 无效的参数
+	JSONRPC_INTERNAL_ERR         = -32603 // 内部错误
+	JSONRPC_SERVER_ERR           = -32000 // 业务错误, data中带有gRPC状态码及错误码
+)
+
+const jsonRPCVersion = "2.0"
+
+var jsonRPCNullID = json.RawMessage("null")
+
+type jsonRPCRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      json.RawMessage `json:"id,omitempty"`
+}
+
+type jsonRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *jsonRPCError   `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type jsonRPCError struct {
+	Code    int               `json:"code"`
+	Message string            `json:"message"`
+	Data    *jsonRPCErrorData `json:"data,omitempty"`
+}
+
+// jsonRPCErrorData 与http路由的错误响应相同的gRPC状态码及错误码
+type jsonRPCErrorData struct {
+	Code    int32 `json:"code"`
+	ErrCode int   `json:"err_code"`
+}
+
+// newJSONRPCError 与http路由相同方式解析错误, 无效的请求体对应无效的参数
+func newJSONRPCError(err error) *jsonRPCError {
+	statusCode, errCode, formatErr := formatError(err)
+	code := JSONRPC_SERVER_ERR
+	if errCode == INVALID_REQUEST_BODY_ERR {
+		code = JSONRPC_INVALID_PARAMS_ERR
+	}
+	return &jsonRPCError{
+		Code:    code,
+		Message: status.Convert(formatErr).Message(),
+		Data:    &jsonRPCErrorData{Code: int32(statusCode), ErrCode: errCode},
+	}
+}
+
+// jsonRPCMethodName gRPC方法全名对应的JSON-RPC方法名, 如 /greeter.Greeter/Hello 对应 greeter.Greeter.Hello
+func jsonRPCMethodName(rpcMethod string) string {
+	return strings.Replace(strings.TrimPrefix(rpcMethod, "/"), "/", ".", 1)
+}
+
+// jsonRPCMethods 由插件写入的rpc_method生成方法表, 停用的路由不加入
+func jsonRPCMethods(entries []routeEntry, disabled map[string]bool) map[string]routeEntry {
+	methods := make(map[string]routeEntry)
+	for _, e := range entries {
+		if isRPCRoute(e.route) && !disabled[e.key()] {
+			methods[jsonRPCMethodName(e.route.Path)] = e
+		}
+	}
+	return methods
+}
+
+// jsonRPC 将JSON-RPC 2.0调用分发到生成代码中的方法, 支持批量请求
+type jsonRPC struct {
+	methods   func() map[string]routeEntry
+	maxBatch  int
+	bodyLimit int64
+}
+
+// handle 处理单个或批量请求, 全部为通知时以204响应
+func (j *jsonRPC) handle(req *restful.Request, rep *restful.Response) {
+	if req.Request.Body == nil {
+		req.Request.Body = http.NoBody
+	}
+	limitBody(req.Request, j.bodyLimit)
+	body, err := ioutil.ReadAll(req.Request.Body)
+	if err == ErrBodyTooLarge {
+		Response(newContext(req, rep), nil, err)
+		return
+	}
+	body = bytes.TrimSpace(body)
+	if err != nil || !json.Valid(body) {
+		j.write(rep, &jsonRPCResponse{JSONRPC: jsonRPCVersion, ID: jsonRPCNullID, Error: &jsonRPCError{Code: JSONRPC_PARSE_ERR, Message: "parse error"}})
+		return
+	}
+	if body[0] != '[' {
+		j.write(rep, j.call(req.Request, body))
+		return
+	}
+	var batch []json.RawMessage
+	json.Unmarshal(body, &batch)
+	if len(batch) == 0 || (j.maxBatch > 0 && len(batch) > j.maxBatch) {
+		j.write(rep, &jsonRPCResponse{JSONRPC: jsonRPCVersion, ID: jsonRPCNullID, Error: &jsonRPCError{Code: JSONRPC_INVALID_REQUEST_ERR, Message: "invalid batch size"}})
+		return
+	}
+	results := make([]*jsonRPCResponse, len(batch))
+	var wg sync.WaitGroup
+	for i := range batch {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = j.call(req.Request, batch[i])
+		}(i)
+	}
+	wg.Wait()
+	responses := make([]*jsonRPCResponse, 0, len(results))
+	for _, r := range results {
+		if r != nil {
+			responses = append(responses, r)
+		}
+	}
+	if len(responses) == 0 {
+		rep.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rep.WriteHeaderAndJson(http.StatusOK, responses, "application/json;charset=utf-8")
+}
+
+func (j *jsonRPC) write(rep *restful.Response, resp *jsonRPCResponse) {
+	if resp == nil {
+		rep.WriteHeader(http.StatusNoContent)
+		return
+	}
+	rep.WriteHeaderAndJson(http.StatusOK, resp, "application/json;charset=utf-8")
+}
+
+// call 调用一个方法, 通知返回nil
+func (j *jsonRPC) call(parent *http.Request, raw json.RawMessage) *jsonRPCResponse {
+	var r jsonRPCRequest
+	if err := json.Unmarshal(raw, &r); err != nil || r.JSONRPC != jsonRPCVersion || r.Method == "" {
+		id := r.ID
+		if len(id) == 0 {
+			id = jsonRPCNullID
+		}
+		return &jsonRPCResponse{JSONRPC: jsonRPCVersion, ID: id, Error: &jsonRPCError{Code: JSONRPC_INVALID_REQUEST_ERR, Message: "invalid request"}}
+	}
+	resp := &jsonRPCResponse{JSONRPC: jsonRPCVersion, ID: r.ID}
+	entry, ok := j.methods()[r.Method]
+	if !ok {
+		resp.Error = &jsonRPCError{Code: JSONRPC_METHOD_NOT_FOUND_ERR, Message: "method '" + r.Method + "' not found"}
+	} else {
+		p := &rpcProtocol{kind: rpcJSONRPC, params: r.Params}
+		entry.handler(newJSONRPCRequest(parent, entry.route, p), restful.NewResponse(&discardResponseWriter{header: http.Header{}}))
+		switch {
+		case !p.finished:
+			openlogging.GetLogger().Errorf("json-rpc method '%s' finished without response", r.Method)
+			resp.Error = &jsonRPCError{Code: JSONRPC_INTERNAL_ERR, Message: "internal error"}
+		case p.err != nil:
+			resp.Error = newJSONRPCError(p.err)
+		case p.result != nil:
+			resp.Result = p.result
+		default:
+			resp.Result = struct{}{}
+		}
+	}
+	// 没有id的请求为通知, 不返回结果
+	if len(r.ID) == 0 {
+		return nil
+	}
+	return resp
+}
+
+// newJSONRPCRequest 以方法的gRPC-Web及Connect路由构造请求, 头域取自JSON-RPC或GraphQL请求
+func newJSONRPCRequest(parent *http.Request, route Route, p *rpcProtocol) *restful.Request {
+	req := parent.WithContext(context.WithValue(parent.Context(), rpcProtocolKey{}, p))
+	req.Method = http.MethodPost
+	req.URL = &url.URL{Path: route.Path}
+	req.RequestURI = route.Path
+	req.Body = http.NoBody
+	req.ContentLength = 0
+	req.Header = make(http.Header, len(parent.Header))
+	for k, v := range parent.Header {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	// 请求体已经读取, 结果不写入响应, 不需要编码
+	req.Header.Del(Header_content_encoding)
+	req.Header.Del(Header_content_length)
+	req.Header.Del(Header_accept_encoding)
+	return restful.NewRequest(req)
+}
+
+// discardResponseWriter JSON-RPC及GraphQL调用的结果由rpcProtocol暂存, 写入的内容丢弃
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
+
+func (w *discardResponseWriter) WriteHeader(int) {}
@@ -0,0 +1,95 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func jsonRPCDo(t *testing.T, m http.Handler, body string) (int, interface{}) {
+	req := httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(body))
+	req.Header.Set(Header_content_type, "application/json")
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, req)
+	var out interface{}
+	if rw.Body.Len() > 0 {
+		assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &out))
+	}
+	return rw.Code, out
+}
+
+func TestJSONRPC(t *testing.T) {
+	initLogger()
+	m, err := NewMux(&rpcGreeter{})
+	assert.NoError(t, err)
+	assert.NoError(t, m.HandleJSONRPC("/rpc", 3))
+
+	code, out := jsonRPCDo(t, m, `{"jsonrpc":"2.0","method":"gwtest.Greeter.Hello","params":{"value":"world"},"id":1}`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "result": map[string]interface{}{"value": "hello world"}, "id": float64(1)}, out)
+
+	// 业务错误带有gRPC状态码及错误码
+	_, out = jsonRPCDo(t, m, `{"jsonrpc":"2.0","method":"gwtest.Greeter.Hello","params":{},"id":"a"}`)
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": "a", "error": map[string]interface{}{
+		"code": float64(JSONRPC_SERVER_ERR), "message": "(10001)not found",
+		"data": map[string]interface{}{"code": float64(5), "err_code": float64(10001)},
+	}}, out)
+
+	_, out = jsonRPCDo(t, m, `{"jsonrpc":"2.0","method":"gwtest.Greeter.Hello","params":{"value":1},"id":2}`)
+	assert.Equal(t, float64(JSONRPC_INVALID_PARAMS_ERR), out.(map[string]interface{})["error"].(map[string]interface{})["code"])
+
+	_, out = jsonRPCDo(t, m, `{"jsonrpc":"2.0","method":"gwtest.Greeter.Missing","id":3}`)
+	assert.Equal(t, float64(JSONRPC_METHOD_NOT_FOUND_ERR), out.(map[string]interface{})["error"].(map[string]interface{})["code"])
+
+	_, out = jsonRPCDo(t, m, `{"jsonrpc":`)
+	assert.Equal(t, map[string]interface{}{"jsonrpc": "2.0", "id": nil, "error": map[string]interface{}{
+		"code": float64(JSONRPC_PARSE_ERR), "message": "parse error",
+	}}, out)
+
+	// 通知不返回结果
+	code, out = jsonRPCDo(t, m, `{"jsonrpc":"2.0","method":"gwtest.Greeter.Hello","params":{"value":"x"}}`)
+	assert.Equal(t, http.StatusNoContent, code)
+	assert.Nil(t, out)
+}
+
+func TestJSONRPCBatch(t *testing.T) {
+	initLogger()
+	m, err := NewMux(&rpcGreeter{})
+	assert.NoError(t, err)
+	assert.NoError(t, m.HandleJSONRPC("/rpc", 3))
+
+	code, out := jsonRPCDo(t, m, `[
+		{"jsonrpc":"2.0","method":"gwtest.Greeter.Hello","params":{"value":"a"},"id":1},
+		{"jsonrpc":"2.0","method":"gwtest.Greeter.Hello","params":{"value":"b"}},
+		{"jsonrpc":"1.0","method":"gwtest.Greeter.Hello","id":3}
+	]`)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"jsonrpc": "2.0", "result": map[string]interface{}{"value": "hello a"}, "id": float64(1)},
+		map[string]interface{}{"jsonrpc": "2.0", "id": float64(3), "error": map[string]interface{}{
+			"code": float64(JSONRPC_INVALID_REQUEST_ERR), "message": "invalid request",
+		}},
+	}, out)
+
+	for _, body := range []string{`[]`, `[1,2,3,4]`} {
+		_, out = jsonRPCDo(t, m, body)
+		assert.Equal(t, float64(JSONRPC_INVALID_REQUEST_ERR), out.(map[string]interface{})["error"].(map[string]interface{})["code"])
+	}
+
+	code, _ = jsonRPCDo(t, m, `[{"jsonrpc":"2.0","method":"gwtest.Greeter.Hello"}]`)
+	assert.Equal(t, http.StatusNoContent, code)
+}
+
+func TestJSONRPCMethods(t *testing.T) {
+	var entries []routeEntry
+	for _, route := range withRPCRoutes(new(rpcGreeter).URLPatterns()) {
+		entries = append(entries, routeEntry{route: route})
+	}
+	methods := jsonRPCMethods(entries, map[string]bool{routeKey("", "/gwtest.Greeter/Export", http.MethodPost): true})
+	assert.Len(t, methods, 1)
+	assert.Equal(t, "/gwtest.Greeter/Hello", methods["gwtest.Greeter.Hello"].route.Path)
+}
@@ -0,0 +1,194 @@
+package restful
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-mesh/openlogging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 维护模式配置, 配置变化时立即生效
+const (
+	MaintenanceConfigPrefix      = "cse.restful.maintenance"
+	MaintenanceEnableKey         = MaintenanceConfigPrefix + ".enable"       // 全局维护
+	MaintenanceVersionsKey       = MaintenanceConfigPrefix + ".versions"     // 逗号分隔的路由版本
+	MaintenanceRoutesKey         = MaintenanceConfigPrefix + ".routes"       // 逗号分隔的路由, 格式为 "GET /v1/hello"
+	MaintenanceRetryAfterKey     = MaintenanceConfigPrefix + ".retryAfter"   // Retry-After秒数
+	MaintenanceAllowIPsKey       = MaintenanceConfigPrefix + ".allowIPs"     // 逗号分隔的IP或网段, 可绕过维护
+	MaintenanceAllowHeadersKey   = MaintenanceConfigPrefix + ".allowHeaders" // 逗号分隔的 name=value, 可绕过维护
+	defaultMaintenanceRetryAfter = 300
+)
+
+// ErrMaintenance 维护中返回的错误
+var ErrMaintenance = status.Errorf(codes.Unavailable, "(%d)service under maintenance", MAINTENANCE_ERR)
+
+// maintenance 维护模式状态, 可以全局、按路由版本或按路由开启
+// 运行时的设置在维护配置变化后以配置为准
+type maintenance struct {
+	mu           sync.RWMutex
+	global       bool
+	versions     map[string]bool
+	routes       map[string]bool
+	retryAfter   int
+	allowNets    []*net.IPNet
+	allowHeaders map[string]string
+}
+
+func newMaintenance() *maintenance {
+	return &maintenance{
+		versions:     make(map[string]bool),
+		routes:       make(map[string]bool),
+		retryAfter:   defaultMaintenanceRetryAfter,
+		allowHeaders: make(map[string]string),
+	}
+}
+
+// routeKey 维护模式中路由的标识
+func routeKey(version, path, method string) string {
+	return strings.ToUpper(method) + " " + routeTemplate(Route{Version: version, Path: path})
+}
+
+// splitList 分割逗号分隔的配置
+func splitList(v string) []string {
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// Event 配置变化时重新加载维护模式配置
+func (m *maintenance) Event(e *event.Event) {
+	openlogging.GetLogger().Infof("maintenance config '%s' changed, reload", e.Key)
+	m.load()
+}
+
+// load 从配置中加载维护模式
+func (m *maintenance) load() {
+	versions := make(map[string]bool)
+	for _, v := range splitList(archaius.GetString(MaintenanceVersionsKey, "")) {
+		versions[v] = true
+	}
+	routes := make(map[string]bool)
+	for _, r := range splitList(archaius.GetString(MaintenanceRoutesKey, "")) {
+		if fields := strings.Fields(r); len(fields) == 2 {
+			routes[routeKey("", fields[1], fields[0])] = true
+		} else {
+			openlogging.GetLogger().Warnf("invalid maintenance route '%s'", r)
+		}
+	}
+	var nets []*net.IPNet
+	for _, ip := range splitList(archaius.GetString(MaintenanceAllowIPsKey, "")) {
+		if ipNet := parseIPNet(ip); ipNet != nil {
+			nets = append(nets, ipNet)
+		} else {
+			openlogging.GetLogger().Warnf("invalid maintenance allow ip '%s'", ip)
+		}
+	}
+	headers := make(map[string]string)
+	for _, h := range splitList(archaius.GetString(MaintenanceAllowHeadersKey, "")) {
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) != 2 {
+			openlogging.GetLogger().Warnf("invalid maintenance allow header '%s'", h)
+			continue
+		}
+		headers[http.CanonicalHeaderKey(strings.TrimSpace(kv[0]))] = strings.TrimSpace(kv[1])
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.global = archaius.GetBool(MaintenanceEnableKey, false)
+	m.versions = versions
+	m.routes = routes
+	m.retryAfter = archaius.GetInt(MaintenanceRetryAfterKey, defaultMaintenanceRetryAfter)
+	m.allowNets = nets
+	m.allowHeaders = headers
+}
+
+// parseIPNet 解析IP或网段
+func parseIPNet(v string) *net.IPNet {
+	if !strings.Contains(v, "/") {
+		if ip := net.ParseIP(v); ip != nil {
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		}
+		return nil
+	}
+	_, ipNet, err := net.ParseCIDR(v)
+	if err != nil {
+		return nil
+	}
+	return ipNet
+}
+
+// enabled 路由是否处于维护中
+func (m *maintenance) enabled(route Route) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.global || m.versions[route.Version] || m.routes[routeKey(route.Version, route.Path, route.Method)]
+}
+
+// bypass 请求是否可以绕过维护
+func (m *maintenance) bypass(req *http.Request) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for name, value := range m.allowHeaders {
+		if req.Header.Get(name) == value {
+			return true
+		}
+	}
+	if len(m.allowNets) == 0 {
+		return false
+	}
+	return ipInNets(net.ParseIP(defaultTrustedProxies.clientIP(req)), m.allowNets)
+}
+
+// allow 维护中的路由以503响应并返回false
+func (m *maintenance) allow(route Route, req *restful.Request, rep *restful.Response) bool {
+	if m == nil || !m.enabled(route) || m.bypass(req.Request) {
+		return true
+	}
+	m.mu.RLock()
+	retryAfter := m.retryAfter
+	m.mu.RUnlock()
+	rep.AddHeader(Header_retry_after, strconv.Itoa(retryAfter))
+	Response(newContext(req, rep), nil, ErrMaintenance)
+	return false
+}
+
+// SetMaintenance 开启或关闭全局维护
+func (r *RestfulServer) SetMaintenance(on bool) {
+	r.maintenance.mu.Lock()
+	defer r.maintenance.mu.Unlock()
+	r.maintenance.global = on
+}
+
+// SetVersionMaintenance 开启或关闭路由版本的维护
+func (r *RestfulServer) SetVersionMaintenance(version string, on bool) {
+	r.maintenance.mu.Lock()
+	defer r.maintenance.mu.Unlock()
+	r.maintenance.versions[version] = on
+}
+
+// SetRouteMaintenance 开启或关闭路由的维护
+// @version 路由版本
+// @path 路由路径
+// @method 路由方式
+func (r *RestfulServer) SetRouteMaintenance(version, path, method string, on bool) {
+	r.maintenance.mu.Lock()
+	defer r.maintenance.mu.Unlock()
+	r.maintenance.routes[routeKey(version, path, method)] = on
+}
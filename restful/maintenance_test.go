@@ -0,0 +1,67 @@
+package restful
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaintenance(t *testing.T) {
+	initLogger()
+	r := &RestfulServer{maintenance: newMaintenance()}
+	hello := Route{Method: http.MethodGet, Path: "/hello", Version: "v1", ResourceFuncName: "Hello"}
+	world := Route{Method: http.MethodGet, Path: "/world", Version: "v2", ResourceFuncName: "World"}
+	call := func(route Route, header http.Header, remoteAddr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(route.Method, routeTemplate(route), nil)
+		if header != nil {
+			req.Header = header
+		}
+		req.RemoteAddr = remoteAddr
+		rw := httptest.NewRecorder()
+		if r.maintenance.allow(route, restful.NewRequest(req), restful.NewResponse(rw)) {
+			rw.WriteHeader(http.StatusOK)
+		}
+		return rw
+	}
+
+	assert.Equal(t, http.StatusOK, call(hello, nil, "").Code)
+
+	r.SetRouteMaintenance("v1", "/hello", "get", true)
+	rw := call(hello, nil, "")
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+	assert.Equal(t, "300", rw.Header().Get(Header_retry_after))
+	assert.Contains(t, rw.Body.String(), "10410")
+	assert.Equal(t, http.StatusOK, call(world, nil, "").Code)
+
+	r.SetRouteMaintenance("v1", "/hello", http.MethodGet, false)
+	r.SetVersionMaintenance("v2", true)
+	assert.Equal(t, http.StatusOK, call(hello, nil, "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, call(world, nil, "").Code)
+
+	r.SetVersionMaintenance("v2", false)
+	r.SetMaintenance(true)
+	assert.Equal(t, http.StatusServiceUnavailable, call(hello, nil, "").Code)
+
+	r.maintenance.allowNets = []*net.IPNet{parseIPNet("10.0.0.0/8"), parseIPNet("192.168.1.1")}
+	r.maintenance.allowHeaders = map[string]string{"X-Maintenance-Bypass": "secret"}
+	assert.Equal(t, http.StatusOK, call(hello, nil, "10.1.2.3:80").Code)
+	assert.Equal(t, http.StatusOK, call(hello, nil, "192.168.1.1:80").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, call(hello, nil, "192.168.1.2:80").Code)
+	assert.Equal(t, http.StatusOK, call(hello, http.Header{"X-Maintenance-Bypass": {"secret"}}, "").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, call(hello, http.Header{"X-Maintenance-Bypass": {"guess"}}, "").Code)
+
+	// 直连地址不是可信代理时不使用x-forwarded-for
+	spoofed := http.Header{"X-Forwarded-For": {"10.1.2.3"}}
+	assert.Equal(t, http.StatusServiceUnavailable, call(hello, spoofed, "1.2.3.4:80").Code)
+	defer func(nets []*net.IPNet) { defaultTrustedProxies.nets = nets }(defaultTrustedProxies.nets)
+	defaultTrustedProxies.nets = []*net.IPNet{parseIPNet("172.16.0.0/12")}
+	assert.Equal(t, http.StatusOK, call(hello, spoofed, "172.16.0.1:80").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, call(hello, http.Header{"X-Forwarded-For": {"10.1.2.3, 1.2.3.4"}}, "172.16.0.1:80").Code)
+
+	var nilMaintenance *maintenance
+	assert.True(t, nilMaintenance.allow(hello, nil, nil))
+}
@@ -0,0 +1,248 @@
+package restful
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/go-mesh/openlogging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 上传文件的默认限制
+var (
+	DefaultUploadMaxPartSize int64 = 32 << 20
+	DefaultUploadMaxParts          = 16
+)
+
+// uploadSpec 路由的文件上传规则
+type uploadSpec struct {
+	fields      map[string]bool // 接收文件的字段
+	maxPartSize int64
+	maxParts    int
+	temp        bool // 文件写入临时文件而不是内存
+}
+
+// routeUpload 根据路由元数据生成文件上传规则
+func routeUpload(route Route) (*uploadSpec, error) {
+	u := &uploadSpec{
+		fields:      make(map[string]bool),
+		maxPartSize: DefaultUploadMaxPartSize,
+		maxParts:    DefaultUploadMaxParts,
+		temp:        route.Metadata[UPLOAD_TEMP_METADATA] == "true",
+	}
+	for _, f := range splitList(route.Metadata[UPLOAD_FIELDS_METADATA]) {
+		u.fields[f] = true
+	}
+	var err error
+	if v := route.Metadata[UPLOAD_MAX_PART_SIZE_METADATA]; v != "" {
+		if u.maxPartSize, err = parseSize(v); err != nil {
+			return nil, err
+		}
+	}
+	if v := route.Metadata[UPLOAD_MAX_PARTS_METADATA]; v != "" {
+		if u.maxParts, err = strconv.Atoi(v); err != nil || u.maxParts <= 0 {
+			return nil, fmt.Errorf("invalid upload max parts '%s'", v)
+		}
+	}
+	return u, nil
+}
+
+func invalidMultipart(format string, args ...interface{}) error {
+	return status.Errorf(codes.InvalidArgument, "(%d)"+format, append([]interface{}{INVALID_MULTIPART_ERR}, args...)...)
+}
+
+// isMultipart 请求体是否为multipart/form-data
+func (bs *Context) isMultipart() bool {
+	mediaType, _, err := mime.ParseMediaType(bs.ReadHeader(Header_content_type))
+	return err == nil && mediaType == MimeMult
+}
+
+// ReadMultipart 解析multipart/form-data请求体
+// 文件绑定到路由upload_fields中的bytes或Upload字段, 其余表单值按字段名绑定
+func (bs *Context) ReadMultipart(schema interface{}) error {
+	spec := bs.upload
+	if spec == nil {
+		spec = &uploadSpec{maxPartSize: DefaultUploadMaxPartSize, maxParts: DefaultUploadMaxParts}
+	}
+	reader, err := bs.Req.Request.MultipartReader()
+	if err != nil {
+		return invalidMultipart("invalid multipart body: %s", err.Error())
+	}
+	val := reflect.ValueOf(schema)
+	if val.Kind() != reflect.Ptr || val.Elem().Kind() != reflect.Struct {
+		return invalidMultipart("multipart body can not bind to %T", schema)
+	}
+	fields := protoFields(val.Elem().Type())
+	form := make(map[string][]string)
+	for parts := 0; ; parts++ {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return bodyReadError(err)
+		}
+		if parts >= spec.maxParts {
+			return invalidMultipart("too many parts, max %d", spec.maxParts)
+		}
+		name := part.FormName()
+		if part.FileName() == "" {
+			value, err := readPart(part, spec.maxPartSize)
+			if err != nil {
+				return err
+			}
+			if f, ok := fields[name]; ok {
+				name = formName(f)
+			}
+			form[name] = append(form[name], string(value))
+			continue
+		}
+		f, ok := fields[name]
+		if !ok || !spec.fields[protoName(f)] {
+			return invalidMultipart("unexpected file field '%s'", name)
+		}
+		upload := &Upload{
+			Filename:    part.FileName(),
+			ContentType: part.Header.Get(Header_content_type),
+		}
+		if spec.temp {
+			if err := bs.writeTemp(part, upload, spec.maxPartSize); err != nil {
+				return err
+			}
+		} else if upload.Data, err = readPart(part, spec.maxPartSize); err != nil {
+			return err
+		}
+		if upload.Size == 0 {
+			upload.Size = int64(len(upload.Data))
+		}
+		if err := setUpload(val.Elem().FieldByIndex(f.Index), upload); err != nil {
+			return invalidMultipart("field '%s': %s", name, err.Error())
+		}
+	}
+	if err := mapForm(schema, form); err != nil {
+		return invalidMultipart("invalid form value: %s", err.Error())
+	}
+	return bs.ReadQueryEntity(schema)
+}
+
+// bodyReadError 读取请求体的错误, 超过BodyLimit时保持ErrBodyTooLarge
+func bodyReadError(err error) error {
+	if err == ErrBodyTooLarge {
+		return err
+	}
+	return invalidMultipart("invalid multipart body: %s", err.Error())
+}
+
+// readPart 读取表单项, 超过大小限制时返回ErrBodyTooLarge
+func readPart(part io.Reader, maxSize int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(part, maxSize+1))
+	if err != nil {
+		return nil, bodyReadError(err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, ErrBodyTooLarge
+	}
+	return data, nil
+}
+
+// writeTemp 将文件写入临时文件, 请求结束后删除
+func (bs *Context) writeTemp(part io.Reader, upload *Upload, maxSize int64) error {
+	f, err := ioutil.TempFile("", "restful-upload-")
+	if err != nil {
+		return status.Errorf(codes.Internal, "(%d)create upload temp file failed: %s", INTERNAL_ERR, err.Error())
+	}
+	defer f.Close()
+	bs.tempFiles = append(bs.tempFiles, f.Name())
+	upload.Path = f.Name()
+	if upload.Size, err = io.Copy(f, io.LimitReader(part, maxSize+1)); err != nil {
+		return bodyReadError(err)
+	}
+	if upload.Size > maxSize {
+		return ErrBodyTooLarge
+	}
+	return nil
+}
+
+// removeTempFiles 删除请求中创建的临时文件
+func (bs *Context) removeTempFiles() {
+	for _, name := range bs.tempFiles {
+		if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+			openlogging.GetLogger().Warnf("remove upload temp file '%s' failed: %s", name, err.Error())
+		}
+	}
+	bs.tempFiles = nil
+}
+
+var (
+	bytesType  = reflect.TypeOf([]byte(nil))
+	uploadType = reflect.TypeOf(&Upload{})
+)
+
+// setUpload 将文件写入bytes, *Upload或对应的repeated字段
+func setUpload(field reflect.Value, upload *Upload) error {
+	if !field.IsValid() || !field.CanSet() {
+		return status.Errorf(codes.InvalidArgument, "field can not be set")
+	}
+	var value reflect.Value
+	switch elem := field.Type(); {
+	case elem == bytesType:
+		field.Set(reflect.ValueOf(upload.Data))
+		return nil
+	case elem == uploadType:
+		field.Set(reflect.ValueOf(upload))
+		return nil
+	case elem.Kind() == reflect.Slice && elem.Elem() == bytesType:
+		value = reflect.ValueOf(upload.Data)
+	case elem.Kind() == reflect.Slice && elem.Elem() == uploadType:
+		value = reflect.ValueOf(upload)
+	default:
+		return status.Errorf(codes.InvalidArgument, "type %s can not receive file", elem)
+	}
+	field.Set(reflect.Append(field, value))
+	return nil
+}
+
+// protoFields 返回字段名, form名, proto字段名及json名对应的结构体字段
+func protoFields(typ reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		fields[f.Name] = f
+		if name := f.Tag.Get("form"); name != "" {
+			fields[name] = f
+		}
+		for _, opt := range strings.Split(f.Tag.Get("protobuf"), ",") {
+			if strings.HasPrefix(opt, "name=") {
+				fields[opt[len("name="):]] = f
+			} else if strings.HasPrefix(opt, "json=") {
+				fields[opt[len("json="):]] = f
+			}
+		}
+	}
+	return fields
+}
+
+// protoName 字段的proto字段名
+func protoName(f reflect.StructField) string {
+	for _, opt := range strings.Split(f.Tag.Get("protobuf"), ",") {
+		if strings.HasPrefix(opt, "name=") {
+			return opt[len("name="):]
+		}
+	}
+	return f.Name
+}
+
+// formName mapForm中字段对应的表单名
+func formName(f reflect.StructField) string {
+	if name := f.Tag.Get("form"); name != "" {
+		return name
+	}
+	return f.Name
+}
@@ -0,0 +1,118 @@
+package restful
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+type uploadRequest struct {
+	Title       string    `protobuf:"bytes,1,opt,name=title,proto3" json:"title,omitempty"`
+	PageSize    int32     `protobuf:"varint,2,opt,name=page_size,json=pageSize,proto3" json:"page_size,omitempty"`
+	Avatar      []byte    `protobuf:"bytes,3,opt,name=avatar,proto3" json:"avatar,omitempty"`
+	Attachment  *Upload   `protobuf:"bytes,4,opt,name=attachment,proto3" json:"attachment,omitempty"`
+	Attachments []*Upload `protobuf:"bytes,5,rep,name=attachments,proto3" json:"attachments,omitempty"`
+}
+
+func newMultipartContext(t *testing.T, route Route, build func(w *multipart.Writer)) *Context {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	build(w)
+	assert.NoError(t, w.Close())
+	r, _ := http.NewRequest(http.MethodPost, "/upload", &body)
+	r.Header.Set(Header_content_type, w.FormDataContentType())
+	upload, err := routeUpload(route)
+	assert.NoError(t, err)
+	bs := NewBaseServer(context.TODO())
+	bs.Req = restful.NewRequest(r)
+	bs.Resp = restful.NewResponse(httptest.NewRecorder())
+	bs.upload = upload
+	return bs
+}
+
+func TestReadMultipart(t *testing.T) {
+	route := Route{Metadata: map[string]string{UPLOAD_FIELDS_METADATA: "avatar,attachment,attachments"}}
+	bs := newMultipartContext(t, route, func(w *multipart.Writer) {
+		w.WriteField("title", "hello")
+		w.WriteField("pageSize", "10")
+		fw, _ := w.CreateFormFile("avatar", "avatar.png")
+		fw.Write([]byte("png"))
+		fw, _ = w.CreateFormFile("attachment", "a.txt")
+		fw.Write([]byte("attachment"))
+		fw, _ = w.CreateFormFile("attachments", "b.txt")
+		fw.Write([]byte("b"))
+		fw, _ = w.CreateFormFile("attachments", "c.txt")
+		fw.Write([]byte("cc"))
+	})
+	var req uploadRequest
+	assert.NoError(t, bs.Read(&req))
+	assert.Equal(t, "hello", req.Title)
+	assert.Equal(t, int32(10), req.PageSize)
+	assert.Equal(t, "png", string(req.Avatar))
+	assert.Equal(t, "a.txt", req.Attachment.Filename)
+	assert.Equal(t, "application/octet-stream", req.Attachment.ContentType)
+	assert.Equal(t, int64(10), req.Attachment.Size)
+	assert.Equal(t, "attachment", string(req.Attachment.Data))
+	assert.Len(t, req.Attachments, 2)
+	assert.Equal(t, "cc", string(req.Attachments[1].Data))
+}
+
+func TestReadMultipartTemp(t *testing.T) {
+	route := Route{Metadata: map[string]string{
+		UPLOAD_FIELDS_METADATA: "attachment",
+		UPLOAD_TEMP_METADATA:   "true",
+	}}
+	bs := newMultipartContext(t, route, func(w *multipart.Writer) {
+		fw, _ := w.CreateFormFile("attachment", "a.txt")
+		fw.Write([]byte("attachment"))
+	})
+	var req uploadRequest
+	assert.NoError(t, bs.Read(&req))
+	assert.Empty(t, req.Attachment.Data)
+	assert.Equal(t, int64(10), req.Attachment.Size)
+	data, err := ioutil.ReadFile(req.Attachment.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, "attachment", string(data))
+
+	bs.removeTempFiles()
+	_, err = os.Stat(req.Attachment.Path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestReadMultipartLimits(t *testing.T) {
+	route := Route{Metadata: map[string]string{
+		UPLOAD_FIELDS_METADATA:        "avatar",
+		UPLOAD_MAX_PART_SIZE_METADATA: "4",
+		UPLOAD_MAX_PARTS_METADATA:     "2",
+	}}
+	var req uploadRequest
+
+	bs := newMultipartContext(t, route, func(w *multipart.Writer) {
+		fw, _ := w.CreateFormFile("avatar", "avatar.png")
+		fw.Write([]byte("too large"))
+	})
+	assert.Equal(t, ErrBodyTooLarge, bs.Read(&req))
+
+	bs = newMultipartContext(t, route, func(w *multipart.Writer) {
+		w.WriteField("title", "a")
+		w.WriteField("title", "b")
+		w.WriteField("title", "c")
+	})
+	_, errCode, _ := ParseError(bs.Read(&req))
+	assert.Equal(t, INVALID_MULTIPART_ERR, errCode)
+
+	bs = newMultipartContext(t, route, func(w *multipart.Writer) {
+		fw, _ := w.CreateFormFile("attachment", "a.txt")
+		fw.Write([]byte("a"))
+	})
+	_, errCode, _ = ParseError(bs.Read(&req))
+	assert.Equal(t, INVALID_MULTIPART_ERR, errCode)
+}
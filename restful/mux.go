@@ -0,0 +1,159 @@
+package restful
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+)
+
+// Middleware net/http中间件
+type Middleware func(http.Handler) http.Handler
+
+// Mux 不依赖go-chassis的路由, 直接调用生成的<Service>HttpHandler
+// 不需要初始化config、registry、archaius及lager, 可挂载到任意net/http服务或在测试中使用
+// go-chassis处理链及限流、压缩、访问日志、维护模式等基于配置的功能不生效, 可通过中间件实现
+type Mux struct {
+	container   *restful.Container
+	ws          []*restful.WebService
+	routes      *routeTable
+	middlewares []Middleware
+	handler     http.Handler
+	// JSON-RPC方法表
+	rpcMethods map[string]routeEntry
+	// GraphQL schema及字段表
+	graphqlSchemas []GraphQLSchema
+	graphqlFields  *graphQLFields
+	// BodyLimit 请求体的最大字节数, 0为不限制
+	BodyLimit int64
+}
+
+// NewMux 根据handlers的URLPatterns生成路由
+func NewMux(handlers ...interface{}) (*Mux, error) {
+	m := &Mux{
+		container:     restful.NewContainer(),
+		ws:            []*restful.WebService{new(restful.WebService)},
+		routes:        newRouteTable(),
+		rpcMethods:    make(map[string]routeEntry),
+		graphqlFields: newGraphQLFields(nil, nil),
+	}
+	m.handler = m.container
+	for _, h := range handlers {
+		if err := m.register(h); err != nil {
+			return nil, err
+		}
+	}
+	for _, ws := range m.ws {
+		m.container.Add(ws)
+	}
+	return m, nil
+}
+
+// register 注册handler的所有路由
+func (m *Mux) register(schema interface{}) error {
+	routes, err := GetRouteSpecs(schema)
+	if err != nil {
+		return err
+	}
+	routes = withRPCRoutes(routes)
+	schemaType := reflect.TypeOf(schema)
+	schemaValue := reflect.ValueOf(schema)
+	tokens := strings.Split(schemaType.String(), ".")
+	schemaName := tokens[len(tokens)-1]
+	for _, route := range routes {
+		handler, err := m.wrap(route, schemaType, schemaValue, schemaName)
+		if err != nil {
+			return err
+		}
+		if isRPCRoute(route) {
+			handler = rpcHandler(handler)
+			m.rpcMethods[jsonRPCMethodName(route.Path)] = routeEntry{route: route, handler: handler}
+		} else {
+			m.routes.add(schemaName, route)
+		}
+		if m.ws, err = registe2WebService(m.ws, route, handler); err != nil {
+			return err
+		}
+	}
+	if s, ok := graphQLSchemaOf(schema); ok {
+		m.graphqlSchemas = append(m.graphqlSchemas, s)
+	}
+	m.graphqlFields = newGraphQLFields(m.graphqlSchemas, m.rpcMethods)
+	return nil
+}
+
+// wrap 包装业务函数, 只恢复panic, 不经过go-chassis处理链
+func (m *Mux) wrap(route Route, schemaType reflect.Type, schemaValue reflect.Value, schemaName string) (restful.RouteFunction, error) {
+	call, err := newRouteCall(route, schemaType, schemaValue)
+	if err != nil {
+		return nil, err
+	}
+	observer := newRouteObserver(route, schemaName)
+	return func(req *restful.Request, rep *restful.Response) {
+		defer recoverHandler(req, rep, route, observer)
+		if m.BodyLimit > 0 && req.Request.ContentLength > m.BodyLimit {
+			Response(newContext(req, rep), nil, ErrBodyTooLarge)
+			return
+		}
+		call.call(req.Request.Context(), req, rep, m.BodyLimit)
+	}, nil
+}
+
+// HandleJSONRPC 在path上提供JSON-RPC 2.0接口, 需在处理请求前调用
+// @maxBatch 批量请求的最大调用数, 0为不限制
+func (m *Mux) HandleJSONRPC(path string, maxBatch int) error {
+	j := &jsonRPC{
+		methods:   func() map[string]routeEntry { return m.rpcMethods },
+		maxBatch:  maxBatch,
+		bodyLimit: m.BodyLimit,
+	}
+	var err error
+	m.ws, err = registe2WebService(m.ws, Route{Method: http.MethodPost, Path: path}, j.handle)
+	return err
+}
+
+// HandleGraphQL 在path上提供GraphQL查询, 在path/schema上提供生成的schema, 需在处理请求前调用
+// @maxFields 一次请求的最大字段数, @maxDepth 最大嵌套深度, 小于等于0时使用默认值
+func (m *Mux) HandleGraphQL(path string, maxFields, maxDepth int) error {
+	g := newGraphQL(func() *graphQLFields { return m.graphqlFields }, m.BodyLimit, maxFields, maxDepth, DefaultGraphQLConcurrency)
+	var err error
+	for _, e := range g.routes(path) {
+		if m.ws, err = registe2WebService(m.ws, e.route, e.handler); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Use 添加中间件, 先添加的中间件在外层
+// 需在处理请求前调用
+func (m *Mux) Use(middlewares ...Middleware) *Mux {
+	m.middlewares = append(m.middlewares, middlewares...)
+	var handler http.Handler = m.container
+	for i := len(m.middlewares) - 1; i >= 0; i-- {
+		handler = m.middlewares[i](handler)
+	}
+	m.handler = handler
+	return m
+}
+
+// Container 返回go-restful容器, 不包含中间件
+func (m *Mux) Container() *restful.Container {
+	return m.container
+}
+
+// WebServices 返回注册的webservice, 可添加到已有的go-restful容器中
+func (m *Mux) WebServices() []*restful.WebService {
+	return m.ws
+}
+
+// ListRoutes 获取注册的路由
+func (m *Mux) ListRoutes(filters ...RouteFilter) []RouteInfo {
+	return m.routes.list(filters...)
+}
+
+// ServeHTTP 经过中间件处理请求
+func (m *Mux) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	m.handler.ServeHTTP(w, req)
+}
@@ -0,0 +1,99 @@
+package restful
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// muxGreeter 模拟生成的HttpHandler
+type muxGreeter struct{}
+
+func (h *muxGreeter) URLPatterns() []Route {
+	return []Route{
+		{Method: http.MethodPost, Path: "/hello", Version: "v1", ResourceFuncName: "Hello"},
+		{Method: http.MethodGet, Path: "/missing", Version: "v1", ResourceFuncName: "Missing"},
+		{Method: http.MethodGet, Path: "/panic", Version: "v1", ResourceFuncName: "Panic"},
+	}
+}
+
+func (h *muxGreeter) Hello(ctx *Context) {
+	var req wrapperspb.StringValue
+	if err := ctx.Read(&req); err != nil {
+		Response(ctx, nil, err)
+		return
+	}
+	Response(ctx, &wrapperspb.StringValue{Value: "hello " + req.GetValue()}, nil)
+}
+
+func (h *muxGreeter) Missing(ctx *Context) {
+	Response(ctx, nil, status.Errorf(codes.NotFound, "(%d)not found", 10001))
+}
+
+func (h *muxGreeter) Panic(ctx *Context) {
+	panic("boom")
+}
+
+func TestNewMux(t *testing.T) {
+	m, err := NewMux(&muxGreeter{})
+	assert.NoError(t, err)
+	assert.Len(t, m.ListRoutes(), 3)
+
+	var order []string
+	m.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			order = append(order, "outer")
+			next.ServeHTTP(w, req)
+		})
+	}, func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			order = append(order, "inner")
+			next.ServeHTTP(w, req)
+		})
+	})
+
+	// 挂载到已有的net/http路由
+	mux := http.NewServeMux()
+	mux.Handle("/v1/", m)
+	req := httptest.NewRequest(http.MethodPost, "/v1/hello", strings.NewReader(`{"value":"world"}`))
+	req.Header.Set(Header_content_type, "application/json")
+	rw := httptest.NewRecorder()
+	mux.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Contains(t, rw.Body.String(), "hello world")
+	assert.Equal(t, []string{"outer", "inner"}, order)
+
+	rw = httptest.NewRecorder()
+	m.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/v1/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	assert.Contains(t, rw.Body.String(), "10001")
+
+	rw = httptest.NewRecorder()
+	m.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/v1/panic", nil))
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+}
+
+func TestNewMuxBodyLimit(t *testing.T) {
+	m, err := NewMux(&muxGreeter{})
+	assert.NoError(t, err)
+	m.BodyLimit = 4
+	rw := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPost, "/v1/hello", strings.NewReader(`{"value":"world"}`))
+	req.Header.Set(Header_content_type, "application/json")
+	m.ServeHTTP(rw, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rw.Code)
+}
+
+func TestNewMuxInvalidHandler(t *testing.T) {
+	_, err := NewMux(struct{}{})
+	assert.Error(t, err)
+	_, err = NewMux(errors.New("not a handler"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package restful
+
+import (
+	"context"
+	"io"
+
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// OutgoingContext 将IncommingHeader映射的头域作为gRPC的outgoing metadata
+// 生成的<Service>HttpProxyHandler通过<Service>Client调用远程服务时使用
+func OutgoingContext(ctx *Context) context.Context {
+	md := metadata.MD{}
+	for k, v := range IncommingHeader(ctx) {
+		if v != "" {
+			md.Set(k, v)
+		}
+	}
+	if out, ok := metadata.FromOutgoingContext(ctx.Ctx); ok {
+		md = metadata.Join(out, md)
+	}
+	return metadata.NewOutgoingContext(ctx.Ctx, md)
+}
+
+// HttpBodyClientStream 服务端流式返回HttpBody的客户端流
+// 即生成代码中的 <Service>_<Method>Client 接口
+type HttpBodyClientStream interface {
+	Recv() (*httpbody.HttpBody, error)
+	grpc.ClientStream
+}
+
+// ProxyHttpBodyStream 将客户端流收到的HttpBody以分块传输响应
+// err为发起调用时的错误
+func ProxyHttpBodyStream(ctx *Context, stream HttpBodyClientStream, err error) {
+	out := NewHttpBodyStream(ctx)
+	if err != nil {
+		out.Close(err)
+		return
+	}
+	for {
+		body, err := stream.Recv()
+		if err == io.EOF {
+			out.Close(nil)
+			return
+		}
+		if err == nil {
+			err = out.Send(body)
+		}
+		if err != nil {
+			out.Close(err)
+			return
+		}
+	}
+}
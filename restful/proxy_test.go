@@ -0,0 +1,71 @@
+package restful
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestOutgoingContext(t *testing.T) {
+	initLogger()
+	bs, _ := newHttpBodyContext(http.MethodPut, "/v1/users/1", http.Header{
+		"Authorization": {"Bearer abc"},
+		"If-Match":      {`"v1"`},
+		"X-Request-Id":  {"req-1"},
+		"Cookie":        {"session=secret"},
+	}, "")
+	bs.Ctx = metadata.AppendToOutgoingContext(context.TODO(), "x-caller", "gateway")
+	md, ok := metadata.FromOutgoingContext(OutgoingContext(bs))
+	assert.True(t, ok)
+	assert.Equal(t, []string{"Bearer abc"}, md.Get(Header_x_auth_token))
+	assert.Equal(t, []string{`"v1"`}, md.Get(Header_if_match))
+	assert.Equal(t, []string{"req-1"}, md.Get("x-request-id"))
+	assert.Equal(t, []string{"gateway"}, md.Get("x-caller"))
+	assert.Empty(t, md.Get("cookie"))
+	assert.Empty(t, md.Get(Header_device_name))
+}
+
+// fakeHttpBodyClient 依次返回bodies, 结束后返回err
+type fakeHttpBodyClient struct {
+	grpc.ClientStream
+	bodies []*httpbody.HttpBody
+	err    error
+}
+
+func (c *fakeHttpBodyClient) Recv() (*httpbody.HttpBody, error) {
+	if len(c.bodies) == 0 {
+		return nil, c.err
+	}
+	body := c.bodies[0]
+	c.bodies = c.bodies[1:]
+	return body, nil
+}
+
+func TestProxyHttpBodyStream(t *testing.T) {
+	initLogger()
+	bs, rw := newHttpBodyContext(http.MethodGet, "/export", nil, "")
+	ProxyHttpBodyStream(bs, &fakeHttpBodyClient{bodies: []*httpbody.HttpBody{
+		{ContentType: "text/csv", Data: []byte("id\n")},
+		{Data: []byte("1\n")},
+	}, err: io.EOF}, nil)
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "text/csv", rw.Header().Get(Header_content_type))
+	assert.Equal(t, "id\n1\n", rw.Body.String())
+
+	// 调用失败或未发送内容前出错时以错误响应
+	bs, rw = newHttpBodyContext(http.MethodGet, "/export", nil, "")
+	ProxyHttpBodyStream(bs, nil, status.Errorf(codes.Unavailable, "(%d)connection refused", 10001))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Code)
+
+	bs, rw = newHttpBodyContext(http.MethodGet, "/export", nil, "")
+	ProxyHttpBodyStream(bs, &fakeHttpBodyClient{err: status.Errorf(codes.NotFound, "(%d)not found", 10001)}, nil)
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
@@ -0,0 +1,241 @@
+package restful
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-mesh/openlogging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 限流配置, 路由元数据中的同名字段优先
+// 也可通过 cse.restful.rateLimit.<函数名>.rate 等配置单个路由
+const (
+	RateLimitConfigPrefix = "cse.restful.rateLimit"
+	RATE_LIMIT_METADATA   = "rate_limit"       // 限流速率, 如 100/s, 10/m, 纯数字单位为秒
+	RATE_BURST_METADATA   = "rate_limit_burst" // 令牌桶容量, 默认与每秒速率相同
+	RATE_KEY_METADATA     = "rate_limit_key"   // 限流维度, 逗号分隔, 可选 route,app,tenant,ip
+)
+
+// 限流维度
+const (
+	RateLimitByRoute  = "route"
+	RateLimitByApp    = "app"
+	RateLimitByTenant = "tenant"
+	RateLimitByIP     = "ip"
+)
+
+// RateLimit 令牌桶参数
+type RateLimit struct {
+	Rate  float64 // 每秒产生的令牌数
+	Burst int     // 令牌桶容量
+}
+
+// RateLimitResult 取令牌的结果
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int           // 剩余令牌数
+	RetryAfter time.Duration // 被限流时下一个令牌可用的时间
+	Reset      time.Duration // 令牌桶恢复满的时间
+}
+
+// RateLimitStore 令牌桶存储
+// 默认为进程内存实现, 多实例共享限额时可替换为共享存储的实现
+type RateLimitStore interface {
+	// Take 从key对应的令牌桶中取出一个令牌
+	Take(key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// DefaultRateLimitStore 限流使用的令牌桶存储
+var DefaultRateLimitStore RateLimitStore = NewMemoryRateLimitStore()
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Duration // 从空到满所需的时间
+}
+
+// memoryRateLimitStore 进程内存中的令牌桶
+type memoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	takes   int
+	now     func() time.Time
+}
+
+// NewMemoryRateLimitStore 新建内存令牌桶存储
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Take 取出一个令牌
+func (s *memoryRateLimitStore) Take(key string, limit RateLimit) (RateLimitResult, error) {
+	if limit.Rate <= 0 || limit.Burst <= 0 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit %+v", limit)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	s.sweep(now)
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &tokenBucket{
+			tokens: float64(limit.Burst),
+			last:   now,
+			full:   secondsToDuration(float64(limit.Burst) / limit.Rate),
+		}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	result := RateLimitResult{}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / limit.Rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsToDuration((float64(limit.Burst) - b.tokens) / limit.Rate)
+	return result, nil
+}
+
+// sweep 定期清理已经恢复满的令牌桶, 避免按IP等维度限流时无限增长
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	s.takes++
+	if s.takes < 1024 {
+		return
+	}
+	s.takes = 0
+	for key, b := range s.buckets {
+		if now.Sub(b.last) > b.full {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// parseRate 解析限流速率, 返回每秒的令牌数
+func parseRate(v string) (float64, error) {
+	v = strings.TrimSpace(v)
+	per := time.Second
+	if i := strings.Index(v, "/"); i >= 0 {
+		switch strings.TrimSpace(v[i+1:]) {
+		case "s":
+		case "m":
+			per = time.Minute
+		case "h":
+			per = time.Hour
+		default:
+			return 0, fmt.Errorf("invalid rate limit unit in '%s'", v)
+		}
+		v = strings.TrimSpace(v[:i])
+	}
+	n, err := strconv.ParseFloat(v, 64)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid rate limit '%s'", v)
+	}
+	return n / per.Seconds(), nil
+}
+
+// rateLimiter 单个路由的限流器
+type rateLimiter struct {
+	route string
+	limit RateLimit
+	keys  []string
+}
+
+// newRateLimiter 根据路由元数据及配置生成限流器, 未配置限流时返回nil
+func newRateLimiter(route Route) (*rateLimiter, error) {
+	setting := func(metadataKey, configKey string) string {
+		if v := route.Metadata[metadataKey]; v != "" {
+			return v
+		}
+		if v := archaius.GetString(strings.Join([]string{RateLimitConfigPrefix, route.ResourceFuncName, configKey}, "."), ""); v != "" {
+			return v
+		}
+		return archaius.GetString(RateLimitConfigPrefix+"."+configKey, "")
+	}
+	rate := setting(RATE_LIMIT_METADATA, "rate")
+	if rate == "" {
+		return nil, nil
+	}
+	l := &rateLimiter{route: route.Method + " " + routeTemplate(route)}
+	var err error
+	if l.limit.Rate, err = parseRate(rate); err != nil {
+		return nil, err
+	}
+	l.limit.Burst = int(math.Ceil(l.limit.Rate))
+	if burst := setting(RATE_BURST_METADATA, "burst"); burst != "" {
+		if l.limit.Burst, err = strconv.Atoi(burst); err != nil || l.limit.Burst <= 0 {
+			return nil, fmt.Errorf("invalid rate limit burst '%s'", burst)
+		}
+	}
+	keys := setting(RATE_KEY_METADATA, "key")
+	if keys == "" {
+		keys = RateLimitByRoute + "," + RateLimitByIP
+	}
+	for _, key := range strings.Split(keys, ",") {
+		switch key = strings.TrimSpace(key); key {
+		case RateLimitByRoute, RateLimitByApp, RateLimitByTenant, RateLimitByIP:
+			l.keys = append(l.keys, key)
+		default:
+			return nil, fmt.Errorf("invalid rate limit key '%s'", key)
+		}
+	}
+	return l, nil
+}
+
+// key 根据限流维度生成令牌桶的key
+func (l *rateLimiter) key(req *http.Request) string {
+	parts := make([]string, 0, len(l.keys))
+	for _, key := range l.keys {
+		switch key {
+		case RateLimitByRoute:
+			parts = append(parts, l.route)
+		case RateLimitByApp:
+			parts = append(parts, req.Header.Get(Header_app_id))
+		case RateLimitByTenant:
+			parts = append(parts, req.Header.Get(Header_tenant_name))
+		case RateLimitByIP:
+			parts = append(parts, defaultTrustedProxies.clientIP(req))
+		}
+	}
+	return strings.Join(parts, "|")
+}
+
+// allow 请求是否允许通过, 被限流时以ResourceExhausted错误响应
+func (l *rateLimiter) allow(req *restful.Request, rep *restful.Response) bool {
+	if l == nil {
+		return true
+	}
+	result, err := DefaultRateLimitStore.Take(l.key(req.Request), l.limit)
+	if err != nil {
+		openlogging.GetLogger().Warnf("rate limit store error, let request pass: %s", err.Error())
+		return true
+	}
+	rep.AddHeader(Header_ratelimit_limit, strconv.Itoa(l.limit.Burst))
+	rep.AddHeader(Header_ratelimit_remaining, strconv.Itoa(result.Remaining))
+	rep.AddHeader(Header_ratelimit_reset, strconv.Itoa(int(math.Ceil(result.Reset.Seconds()))))
+	if result.Allowed {
+		return true
+	}
+	rep.AddHeader(Header_retry_after, strconv.Itoa(int(math.Ceil(result.RetryAfter.Seconds()))))
+	Response(newContext(req, rep), nil, status.Errorf(codes.ResourceExhausted, "(%d)too many requests", RATE_LIMIT_ERR))
+	return false
+}
@@ -0,0 +1,122 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		err  bool
+	}{
+		{"10", 10, false},
+		{"100/s", 100, false},
+		{"60/m", 1, false},
+		{"3600 / h", 1, false},
+		{"1/d", 0, true},
+		{"0/s", 0, true},
+		{"abc", 0, true},
+	}
+	for _, tc := range tests {
+		got, err := parseRate(tc.in)
+		if tc.err {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := NewMemoryRateLimitStore().(*memoryRateLimitStore)
+	store.now = func() time.Time { return now }
+	limit := RateLimit{Rate: 1, Burst: 2}
+
+	for i := 0; i < 2; i++ {
+		result, err := store.Take("k", limit)
+		assert.NoError(t, err)
+		assert.True(t, result.Allowed)
+	}
+	result, err := store.Take("k", limit)
+	assert.NoError(t, err)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	assert.Equal(t, time.Second, result.RetryAfter)
+	assert.Equal(t, 2*time.Second, result.Reset)
+
+	result, _ = store.Take("other", limit)
+	assert.True(t, result.Allowed)
+
+	now = now.Add(time.Second)
+	result, _ = store.Take("k", limit)
+	assert.True(t, result.Allowed)
+
+	_, err = store.Take("k", RateLimit{})
+	assert.Error(t, err)
+}
+
+func TestRateLimiter(t *testing.T) {
+	initLogger()
+	old := DefaultRateLimitStore
+	DefaultRateLimitStore = NewMemoryRateLimitStore()
+	defer func() { DefaultRateLimitStore = old }()
+	route := Route{Method: http.MethodGet, Path: "/hello", ResourceFuncName: "Hello", Metadata: map[string]string{
+		RATE_LIMIT_METADATA: "1/m",
+		RATE_BURST_METADATA: "1",
+		RATE_KEY_METADATA:   "route,app",
+	}}
+	l, err := newRateLimiter(route)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{RateLimitByRoute, RateLimitByApp}, l.keys)
+
+	call := func(app string) *httptest.ResponseRecorder {
+		r, _ := http.NewRequest(http.MethodGet, "/hello", nil)
+		r.Header.Set(Header_app_id, app)
+		rw := httptest.NewRecorder()
+		if l.allow(restful.NewRequest(r), restful.NewResponse(rw)) {
+			rw.WriteHeader(http.StatusOK)
+		}
+		return rw
+	}
+	assert.Equal(t, http.StatusOK, call("a").Code)
+	rw := call("a")
+	assert.Equal(t, http.StatusTooManyRequests, rw.Code)
+	assert.Equal(t, "60", rw.Header().Get(Header_retry_after))
+	assert.Equal(t, "1", rw.Header().Get(Header_ratelimit_limit))
+	assert.Equal(t, "0", rw.Header().Get(Header_ratelimit_remaining))
+	assert.Contains(t, rw.Body.String(), "10413")
+	assert.Equal(t, http.StatusOK, call("b").Code)
+
+	// 直连地址不是可信代理时, 伪造x-forwarded-for不会得到新的令牌桶
+	route.Metadata[RATE_KEY_METADATA] = "route,ip"
+	l, err = newRateLimiter(route)
+	assert.NoError(t, err)
+	spoof := func(forwarded string) int {
+		r, _ := http.NewRequest(http.MethodGet, "/hello", nil)
+		r.RemoteAddr = "1.2.3.4:80"
+		r.Header.Set(Header_x_forwarded_for, forwarded)
+		rw := httptest.NewRecorder()
+		if l.allow(restful.NewRequest(r), restful.NewResponse(rw)) {
+			rw.WriteHeader(http.StatusOK)
+		}
+		return rw.Code
+	}
+	assert.Equal(t, http.StatusOK, spoof("10.0.0.1"))
+	assert.Equal(t, http.StatusTooManyRequests, spoof("10.0.0.2"))
+
+	route.Metadata[RATE_KEY_METADATA] = "user"
+	_, err = newRateLimiter(route)
+	assert.Error(t, err)
+
+	var nilLimiter *rateLimiter
+	assert.True(t, nilLimiter.allow(nil, nil))
+}
@@ -0,0 +1,32 @@
+package restful
+
+import (
+	"runtime/debug"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-mesh/openlogging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrInternal 处理请求时发生panic返回的错误
+var ErrInternal = status.Errorf(codes.Internal, "(%d)internal server error", INTERNAL_ERR)
+
+// recoverHandler 恢复处理请求时发生的panic, 记录堆栈并以Internal错误响应
+// 需在defer中直接调用
+func recoverHandler(req *restful.Request, rep *restful.Response, route Route, observer *routeObserver) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	observer.recovered()
+	openlogging.GetLogger().Errorf("panic in route [%s] func [%s]: %v\n%s",
+		routeTemplate(route), route.ResourceFuncName, r, debug.Stack())
+	defer func() {
+		// 响应错误时再次panic则放弃响应
+		if r := recover(); r != nil {
+			openlogging.GetLogger().Errorf("write response after panic failed: %v", r)
+		}
+	}()
+	Response(newContext(req, rep), nil, ErrInternal)
+}
@@ -0,0 +1,56 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-chassis/core/lager"
+	"github.com/stretchr/testify/assert"
+)
+
+func initLogger() {
+	if lager.Logger == nil {
+		lager.Init(&lager.Options{Writers: "stdout", LoggerLevel: "ERROR"})
+	}
+}
+
+func TestRecoverHandler(t *testing.T) {
+	initLogger()
+	route := Route{Method: http.MethodGet, Path: "/panic", ResourceFuncName: "Panic"}
+	observer := newRouteObserver(route, "PanicHttpHandler")
+	r, _ := http.NewRequest(http.MethodGet, "/panic", nil)
+	req := restful.NewRequest(r)
+	rw := httptest.NewRecorder()
+	rep := restful.NewResponse(rw)
+
+	func() {
+		defer recoverHandler(req, rep, route, observer)
+		var token interface{} = 1
+		_ = token.(string)
+	}()
+
+	assert.Equal(t, http.StatusInternalServerError, rw.Code)
+	var body errBody
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &body))
+	assert.Equal(t, INTERNAL_ERR, body.ErrCode)
+}
+
+type outHeadTestResponse struct {
+	Token string
+}
+
+func TestResponseNil(t *testing.T) {
+	initLogger()
+	for _, resp := range []interface{}{nil, (*outHeadTestResponse)(nil), &outHeadTestResponse{Token: "t"}} {
+		r, _ := http.NewRequest(http.MethodGet, "/", nil)
+		rw := httptest.NewRecorder()
+		b := NewBaseServer(r.Context())
+		b.Req = restful.NewRequest(r)
+		b.Resp = restful.NewResponse(rw)
+		assert.NotPanics(t, func() { Response(b, resp, nil) })
+		assert.Equal(t, http.StatusOK, rw.Code)
+	}
+}
@@ -1,12 +1,13 @@
 package restful
 
 import (
+	"context"
 	"fmt"
 	"reflect"
 	"regexp"
 	"strconv"
 
-	"github.com/go-chassis/go-chassis/core/lager"
+	"github.com/go-mesh/openlogging"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -50,6 +51,10 @@ func formatError(err error) (codes.Code, int, error) {
 }
 
 func ParseError(err error) (codes.Code, int, error) {
+	// 上下文超时或取消转换为对应的grpc错误
+	if err == context.DeadlineExceeded || err == context.Canceled {
+		err = status.FromContextError(err).Err()
+	}
 	// 是否能解析错误
 	s, ok := status.FromError(err)
 	if !ok {
@@ -67,6 +72,10 @@ func ParseError(err error) (codes.Code, int, error) {
 	if len(out) >= 1 && len(out[0]) >= 2 {
 		errCode, _ = strconv.Atoi(out[0][1])
 	}
+	if errCode == 0 && statusCode == codes.DeadlineExceeded {
+		message = fmt.Sprintf("(%d)%s", TIMEOUT_ERR, message)
+		errCode = TIMEOUT_ERR
+	}
 	if errCode == 0 {
 		message = fmt.Sprintf("(%d)%s", INVALID_ERR_FORMAT_ERR, message)
 		statusCode = codes.InvalidArgument
@@ -75,39 +84,67 @@ func ParseError(err error) (codes.Code, int, error) {
 	return statusCode, errCode, fmt.Errorf(message)
 }
 
+// writeOutHead 将响应中的指定字段写入头域, 响应为空或字段不是字符串时忽略
+func writeOutHead(b *Context, resp interface{}) {
+	value := reflect.ValueOf(resp)
+	if value.Kind() != reflect.Ptr || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return
+	}
+	value = value.Elem()
+	for k, v := range outHead {
+		field := value.FieldByName(k)
+		if !field.IsValid() || field.Kind() != reflect.String {
+			continue
+		}
+		b.AddHeader(v, field.String())
+		b.AddHeader(Header_auth, field.String())
+	}
+}
+
 /*
 	如果query参数中携带onebox参数且不为空则返回消息体和错误消息体一并返回
 */
 func Response(b *Context, resp interface{}, err error) {
-	lager.Logger.Debugf("response: %v", resp)
-	// 将指定字段解析到头域中
-	t := reflect.TypeOf(resp).Elem()
-	value := reflect.ValueOf(resp).Elem()
-	for k, v := range outHead {
-		for i := 0; i < t.NumField(); i++ {
-			if k == t.Field(i).Name {
-				b.AddHeader(v, value.Field(i).Interface().(string))
-				b.AddHeader(Header_auth, value.Field(i).Interface().(string))
-			}
-		}
+	openlogging.GetLogger().Debugf("response: %s", requestRedactor(b.Req).lazyBody(resp))
+	// gRPC-Web及Connect请求按协议响应
+	if b.rpc != nil {
+		b.rpc.respond(b, resp, err)
+		return
 	}
+	// 按fields查询参数裁剪响应, 头域仍取自完整的响应
+	data := resp
+	if err == nil {
+		data, err = maskResponse(b, resp)
+	}
+	if err == nil {
+		// 将指定字段解析到头域中
+		writeOutHead(b, resp)
+	}
+	origin := resp
+	resp = jsonEntity(data)
 	isonebox := true
-	lager.Logger.Debugf("get onebox parameter '%s'", b.ReadQueryParameter(BODY_INONEBOX_PARAM))
+	openlogging.GetLogger().Debugf("get onebox parameter '%s'", b.ReadQueryParameter(BODY_INONEBOX_PARAM))
 	if onebox := b.ReadQueryParameter(BODY_INONEBOX_PARAM); onebox == "" {
 		isonebox = false
 	}
 	statusCode, errCode, formatErr := formatError(err)
-	httpCode := HTTPStatusFromCode(b, statusCode)
+	httpCode := httpStatusFromError(b, statusCode, errCode)
+	traceResponse(b.Ctx, statusCode, errCode)
+	setResult(b.Req, statusCode, errCode)
 	respBody := RespBody{
 		Message:       "SUCCESS",
 		Data:          resp,
 		Status:        httpCode,
-		RequestId:     b.ReadResponseWriter().Header().Get(Header_trace),
+		RequestId:     requestId(b),
 		RequestMethod: b.ReadResponseWriter().Header().Get(Header_method),
 		Success:       true,
 	}
+	// If-None-Match匹配时以304响应
+	if err == nil && b.etag.notModified(b, origin, resp) {
+		return
+	}
 	if err != nil {
-		lager.Logger.Errorf("request on '%s' with method '%s' got error[%s]",
+		openlogging.GetLogger().Errorf("request on '%s' with method '%s' got error[%s]",
 			b.ReadRequest().URL.String(),
 			b.ReadRequest().Method,
 			err.Error())
@@ -115,12 +152,12 @@ func Response(b *Context, resp interface{}, err error) {
 		respBody.Message = status.Convert(formatErr).Message()
 		respBody.Success = false
 		if !isonebox {
-			b.WriteHeaderAndJSON(HTTPStatusFromCode(b, statusCode),
+			b.WriteHeaderAndJSON(httpCode,
 				errBody{
 					Code:          int32(statusCode),
 					ErrCode:       respBody.ErrCode,
 					Message:       respBody.Message,
-					RequestId:     b.ReadResponseWriter().Header().Get(Header_trace),
+					RequestId:     requestId(b),
 					RequestMethod: b.ReadResponseWriter().Header().Get(Header_method),
 				},
 				"application/json;charset=utf-8")
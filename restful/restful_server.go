@@ -9,6 +9,7 @@ import (
 	"reflect"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/go-chassis/go-chassis/core/registry"
 	"github.com/go-chassis/go-chassis/pkg/util/iputil"
@@ -47,31 +48,110 @@ func init() {
 // RestfulServer restful实现
 type RestfulServer struct {
 	microServiceName string
-	container        *restful.Container
+	// 当前的路由, 变更时整体替换, 由mux保护
+	container *restful.Container
 	// 根据不同的路由版本写入多个webservice
 	ws     []*restful.WebService
 	opts   server.Options
 	mux    sync.RWMutex
 	exit   chan chan error
 	server *http.Server
+	// 路由变更串行执行
+	routeMu  sync.Mutex
+	entries  []routeEntry
+	system   []routeEntry             // metrics及管理接口
+	extraWs  []*restful.WebService    // swagger等直接注册到容器的webservice
+	filters  []restful.FilterFunction // 每次生成容器时添加的过滤器
+	disabled map[string]bool          // 停用的路由
+	// 维护模式
+	maintenance *maintenance
+	// 跨域规则
+	cors *cors
+	// 注册的路由
+	routes *routeTable
+	// 就绪状态及进行中的请求
+	lifecycle *lifecycle
+	shutdown  ShutdownOptions
+	// 就绪检查
+	health *health
+	// JSON-RPC方法表, 与路由一起替换, 由mux保护
+	rpcMethods map[string]routeEntry
+	// GraphQL schema, 注册handler时添加, 由routeMu保护
+	graphqlSchemas []GraphQLSchema
+	// GraphQL字段表, 与路由一起替换, 由mux保护
+	graphqlFields *graphQLFields
 }
 
 // NewRestfulServer 新的restful服务初始化
 func NewRestfulServer(opts server.Options) server.ProtocolServer {
-	ws := new(restful.WebService)
+	var system []routeEntry
 	if archaius.GetBool("cse.metrics.enable", false) {
 		metricPath := archaius.GetString("cse.metrics.apiPath", DefaultMetricPath)
 		if !strings.HasPrefix(metricPath, "/") {
 			metricPath = "/" + metricPath
 		}
 		openlogging.Info("Enabled metrics API on " + metricPath)
-		ws.Route(ws.GET(metricPath).To(metrics.HTTPHandleFunc))
-	}
-	return &RestfulServer{
-		opts:      opts,
-		container: restful.NewContainer(),
-		ws:        []*restful.WebService{ws},
+		system = append(system, routeEntry{route: Route{Method: http.MethodGet, Path: metricPath}, handler: metrics.HTTPHandleFunc})
+	}
+	m := newMaintenance()
+	m.load()
+	if err := archaius.RegisterListener(m, MaintenanceConfigPrefix+".*"); err != nil {
+		openlogging.GetLogger().Warnf("register maintenance config listener failed: %s", err.Error())
+	}
+	defaultTrustedProxies.load()
+	if err := archaius.RegisterListener(defaultTrustedProxies, TrustedProxiesKey); err != nil {
+		openlogging.GetLogger().Warnf("register trusted proxies config listener failed: %s", err.Error())
+	}
+	c := newCors()
+	if err := archaius.RegisterListener(c, CorsConfigPrefix+".*"); err != nil {
+		openlogging.GetLogger().Warnf("register cors config listener failed: %s", err.Error())
+	}
+	r := &RestfulServer{
+		opts:          opts,
+		maintenance:   m,
+		cors:          c,
+		routes:        newRouteTable(),
+		system:        system,
+		lifecycle:     newLifecycle(),
+		shutdown:      loadShutdownOptions(),
+		health:        newHealth(defaultHealthTimeout),
+		graphqlFields: newGraphQLFields(nil, nil),
+	}
+	r.system = append(r.system, r.healthRoutes()...)
+	if archaius.GetBool(RoutesAdminEnableKey, false) {
+		adminPath := archaius.GetString(RoutesAdminPathKey, DefaultRoutesAdminPath)
+		if !strings.HasPrefix(adminPath, "/") {
+			adminPath = "/" + adminPath
+		}
+		openlogging.Info("Enabled routes API on " + adminPath)
+		r.system = append(r.system, routeEntry{route: Route{Method: http.MethodGet, Path: adminPath}, handler: r.handleRoutes})
 	}
+	if archaius.GetBool(JSONRPCEnableKey, false) {
+		rpcPath := archaius.GetString(JSONRPCPathKey, DefaultJSONRPCPath)
+		if !strings.HasPrefix(rpcPath, "/") {
+			rpcPath = "/" + rpcPath
+		}
+		openlogging.Info("Enabled json-rpc API on " + rpcPath)
+		j := &jsonRPC{methods: r.rpcMethodTable, maxBatch: archaius.GetInt(JSONRPCMaxBatchKey, DefaultJSONRPCMaxBatch), bodyLimit: opts.BodyLimit}
+		r.system = append(r.system, routeEntry{route: Route{Method: http.MethodPost, Path: rpcPath}, handler: j.handle})
+	}
+	if archaius.GetBool(GraphQLEnableKey, false) {
+		graphqlPath := archaius.GetString(GraphQLPathKey, DefaultGraphQLPath)
+		if !strings.HasPrefix(graphqlPath, "/") {
+			graphqlPath = "/" + graphqlPath
+		}
+		openlogging.Info("Enabled graphql API on " + graphqlPath)
+		g := newGraphQL(r.graphQLFieldTable, opts.BodyLimit, archaius.GetInt(GraphQLMaxFieldsKey, DefaultGraphQLMaxFields),
+			archaius.GetInt(GraphQLMaxDepthKey, DefaultGraphQLMaxDepth), archaius.GetInt(GraphQLConcurrencyKey, DefaultGraphQLConcurrency))
+		r.system = append(r.system, g.routes(graphqlPath)...)
+	}
+	// 容器级别的过滤器在路由匹配之后、处理链之前执行, 预检请求不会进入处理链
+	r.filters = append(r.filters, c.filter(r.routeVersion))
+	r.loadDisabledRoutes()
+	if err := archaius.RegisterListener(routeSwitch{r: r}, RoutesDisabledKey); err != nil {
+		openlogging.GetLogger().Warnf("register disabled routes config listener failed: %s", err.Error())
+	}
+	return r
 }
 
 // HTTPRequest2Invocation convert http request to uniform invocation data format
@@ -89,11 +169,17 @@ func HTTPRequest2Invocation(req *restful.Request, schema, operation string) (*in
 		},
 	}
 	//set headers to Ctx, then user do not  need to consider about protocol in handlers
+	//Ctx is derived from the request context, so values set by outer middleware and
+	//cancellation of the request are visible to handlers
+	ctx := req.Request.Context()
 	m := make(map[string]string, 0)
-	inv.Ctx = context.WithValue(context.Background(), common.ContextHeaderKey{}, m)
+	for k, v := range common.FromContext(ctx) {
+		m[k] = v
+	}
 	for k := range req.Request.Header {
 		m[k] = req.Request.Header.Get(k)
 	}
+	inv.Ctx = context.WithValue(ctx, common.ContextHeaderKey{}, m)
 	return inv, nil
 }
 
@@ -101,8 +187,8 @@ func HTTPRequest2Invocation(req *restful.Request, schema, operation string) (*in
 func (r *RestfulServer) Register(schema interface{}, options ...server.RegisterOption) (string, error) {
 	openlogging.Info("register rest server")
 	opts := server.RegisterOptions{}
-	r.mux.Lock()
-	defer r.mux.Unlock()
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
 	for _, o := range options {
 		o(&opts)
 	}
@@ -111,6 +197,7 @@ func (r *RestfulServer) Register(schema interface{}, options ...server.RegisterO
 	if err != nil {
 		return "", err
 	}
+	routes = withRPCRoutes(routes)
 	schemaType := reflect.TypeOf(schema)
 	schemaValue := reflect.ValueOf(schema)
 	var schemaName string
@@ -119,88 +206,30 @@ func (r *RestfulServer) Register(schema interface{}, options ...server.RegisterO
 		schemaName = tokens[len(tokens)-1]
 	}
 	lager.Logger.Infof("schema registered is [%s]", schemaName)
+	entries := r.entries
 	for _, route := range routes {
-		handler, err := WrapHandlerChain(route, schemaType, schemaValue, schemaName, r.opts)
+		handler, err := wrapHandlerChain(route, schemaType, schemaValue, schemaName, r.opts, r.maintenance)
 		if err != nil {
 			return "", err
 		}
-		if err := r.registe2GoRestful(route, handler); err != nil {
-			// if err := Register2GoRestful(route, r.ws, handler); err != nil {
-			return "", err
+		if isRPCRoute(route) {
+			handler = rpcHandler(handler)
 		}
+		entries = withEntry(entries, routeEntry{route: route, handler: handler})
 	}
-	for _, ws := range r.ws {
-		lager.Logger.Debugf("root path '%s' routes %+v", ws.RootPath(), ws.Routes())
+	if s, ok := graphQLSchemaOf(schema); ok {
+		r.graphqlSchemas = append(r.graphqlSchemas, s)
 	}
-	return reflect.TypeOf(schema).String(), nil
-}
-
-// registe2GoRestful 注册到go-restful中
-func (r *RestfulServer) registe2GoRestful(routeSpec Route, handler restful.RouteFunction) error {
-	ws, err := r.getWsByRouteVersion(routeSpec.Version)
-	if err != nil {
-		lager.Logger.Warnf("root path '%s' webservice not found, create new", routeSpec.Version)
-		ws = new(restful.WebService)
-		ws = ws.ApiVersion(routeSpec.Version)
-		ws = ws.Path(routeSpec.Version)
-		r.ws = append(r.ws, ws)
-	}
-	var rb *restful.RouteBuilder
-	switch routeSpec.Method {
-	case http.MethodGet:
-		rb = ws.GET(routeSpec.Path)
-	case http.MethodPost:
-		rb = ws.POST(routeSpec.Path)
-	case http.MethodHead:
-		rb = ws.HEAD(routeSpec.Path)
-	case http.MethodPut:
-		rb = ws.PUT(routeSpec.Path)
-	case http.MethodPatch:
-		rb = ws.PATCH(routeSpec.Path)
-	case http.MethodDelete:
-		rb = ws.DELETE(routeSpec.Path)
-	default:
-		return errors.New("method [" + routeSpec.Method + "] do not support")
-	}
-	rb = fillParam(routeSpec, rb)
-
-	for _, r := range routeSpec.Returns {
-		rb = rb.Returns(r.Code, r.Message, r.Model)
-	}
-	if routeSpec.Read != nil {
-		rb = rb.Reads(routeSpec.Read)
-	}
-
-	if len(routeSpec.Consumes) > 0 {
-		rb = rb.Consumes(routeSpec.Consumes...)
-	} else {
-		rb = rb.Consumes("*/*")
-	}
-	if len(routeSpec.Produces) > 0 {
-		rb = rb.Produces(routeSpec.Produces...)
-	} else {
-		rb = rb.Produces("*/*")
+	if err := r.apply(entries); err != nil {
+		return "", err
 	}
-	ws.Route(rb.To(handler).Doc(routeSpec.FuncDesc).Operation(routeSpec.ResourceFuncName))
-
-	return nil
-}
-
-// getWsByRouteVersion 根据不同的route版本选取不同的webservice
-func (r *RestfulServer) getWsByRouteVersion(version string) (*restful.WebService, error) {
-	for _, each := range r.ws {
-		lager.Logger.Debugf("get root path '%s'", each.RootPath())
-		if version != "" {
-			if each.RootPath() == version {
-				return each, nil
-			}
-		} else {
-			if each.RootPath() == version || each.RootPath() == "/" {
-				return each, nil
-			}
+	for _, route := range routes {
+		// rpc路由由rest路由生成, 不记入路由表
+		if !isRPCRoute(route) {
+			r.routes.add(schemaName, route)
 		}
 	}
-	return nil, fmt.Errorf("version '%s' webservice not found", version)
+	return reflect.TypeOf(schema).String(), nil
 }
 
 // AddRoute 添加路由
@@ -213,17 +242,16 @@ func (r *RestfulServer) AddRoute(schema interface{}, route Route) error {
 	if len(tokens) >= 1 {
 		schemaName = tokens[len(tokens)-1]
 	}
-	handler, err := WrapHandlerChain(route, schemaType, schemaValue, schemaName, r.opts)
+	handler, err := wrapHandlerChain(route, schemaType, schemaValue, schemaName, r.opts, r.maintenance)
 	if err != nil {
 		return err
 	}
-	if err := r.registe2GoRestful(route, handler); err != nil {
-		// if err := Register2GoRestful(route, r.ws, handler); err != nil {
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	if err := r.apply(withEntry(r.entries, routeEntry{route: route, handler: handler})); err != nil {
 		return err
 	}
-	for _, ws := range r.ws {
-		lager.Logger.Debugf("root path '%s' routes %+v", ws.RootPath(), ws.Routes())
-	}
+	r.routes.add(schemaName, route)
 	return nil
 }
 
@@ -232,26 +260,23 @@ func (r *RestfulServer) AddRoute(schema interface{}, route Route) error {
 // @path 路由路径
 // @method 路由方式
 func (r *RestfulServer) DelRoute(version, path, method string) error {
-	ws, err := r.getWsByRouteVersion(version)
-	if err != nil {
-		return err
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	entries, ok := withoutEntry(r.entries, routeKey(version, path, method))
+	if !ok {
+		return ErrRouteNotFound
 	}
-	if err := ws.RemoveRoute(path, method); err != nil {
+	if err := r.apply(entries); err != nil {
 		return err
 	}
+	r.routes.del(version, path, method)
 	return nil
 }
 
-// GetRoute 获取路由
-// @version 路由版本
-// @path 路由路径
-// @method 路由方式
-func (r *RestfulServer) GetRoute(version, path, method string) (Route, error) {
-	return Route{}, nil
-}
-
-// GetWebService 获取webservice
+// GetWebService 获取当前路由的webservice
 func (r *RestfulServer) GetWebService() []*restful.WebService {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
 	return r.ws
 }
 
@@ -337,19 +362,18 @@ func (r *RestfulServer) Start() error {
 	r.mux.Lock()
 	r.opts.Address = config.Address
 	r.mux.Unlock()
-	for _, ws := range r.ws {
-		r.container.Add(ws)
-	}
+	// 路由在启动后变更时整体替换, 由ServeHTTP使用当前的路由
 	if r.opts.TLSConfig != nil {
-		r.server = &http.Server{Addr: config.Address, Handler: r.container, TLSConfig: r.opts.TLSConfig}
+		r.server = &http.Server{Addr: config.Address, Handler: r, TLSConfig: r.opts.TLSConfig}
 	} else {
-		r.server = &http.Server{Addr: config.Address, Handler: r.container}
+		r.server = &http.Server{Addr: config.Address, Handler: r}
 	}
 	// create schema
 	err = r.CreateLocalSchema(config)
 	if err != nil {
 		return err
 	}
+	r.keepExtraWebServices()
 	l, lIP, lPort, err := iputil.StartListener(config.Address, config.TLSConfig)
 
 	if err != nil {
@@ -360,12 +384,13 @@ func (r *RestfulServer) Start() error {
 
 	go func() {
 		err = r.server.Serve(l)
-		if err != nil {
+		if err != nil && err != http.ErrServerClosed {
 			openlogging.Error("http server err: " + err.Error())
 			server.ErrRuntime <- err
 		}
 
 	}()
+	atomic.StoreInt32(&r.lifecycle.ready, 1)
 
 	lager.Logger.Infof("Restful server listening on: %s", registry.InstanceEndpoints[config.ProtocolServerName])
 	return nil
@@ -390,13 +415,16 @@ func (r *RestfulServer) CreateLocalSchema(config server.Options) error {
 	swagger.LogInfo = func(format string, v ...interface{}) {
 		openlogging.GetLogger().Infof(format, v...)
 	}
+	r.mux.RLock()
+	container := r.container
+	r.mux.RUnlock()
 	swaggerConfig := swagger.Config{
-		WebServices:     r.container.RegisteredWebServices(),
+		WebServices:     container.RegisteredWebServices(),
 		WebServicesUrl:  config.Address,
 		ApiPath:         "/apidocs.json",
 		FileStyle:       "yaml",
 		SwaggerFilePath: filepath.Join(path, runtime.ServiceName+".yaml")}
-	sws := swagger.RegisterSwaggerService(swaggerConfig, r.container)
+	sws := swagger.RegisterSwaggerService(swaggerConfig, container)
 	openlogging.Info("The schema has been created successfully. path:" + path)
 	//set schema information when create local schema file
 	err := schema.SetSchemaInfo(sws)
@@ -406,20 +434,6 @@ func (r *RestfulServer) CreateLocalSchema(config server.Options) error {
 	return nil
 }
 
-// Stop stop restful server
-func (r *RestfulServer) Stop() error {
-	if r.server == nil {
-		openlogging.Info("http server never started")
-		return nil
-	}
-	//only golang 1.8 support graceful shutdown.
-	if err := r.server.Shutdown(context.TODO()); err != nil {
-		openlogging.Warn("http shutdown error: " + err.Error())
-		return err // failure/timeout shutting down the server gracefully
-	}
-	return nil
-}
-
 // String get server name
 func (r *RestfulServer) String() string {
 	return Name
@@ -7,8 +7,8 @@ import (
 	"path/filepath"
 	"testing"
 
-	"github.com/emicklei/go-restful"
 	rf "github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
 	"github.com/go-chassis/go-chassis/core/config"
 	"github.com/go-chassis/go-chassis/core/config/model"
 	"github.com/go-chassis/go-chassis/core/lager"
@@ -25,7 +25,7 @@ func initEnv() {
 	log.Println(os.Getenv("CHASSIS_HOME"))
 	os.Setenv("GO_CHASSIS_SWAGGERFILEPATH", filepath.Join(p, "src", "github.com", "go-chassis", "go-chassis", "examples", "discovery", "server"))
 	log.Println(os.Getenv("GO_CHASSIS_SWAGGERFILEPATH"))
-	lager.Initialize("", "INFO", "", "size", true, 1, 10, 7)
+	lager.Init(&lager.Options{Writers: "stdout", LoggerLevel: "INFO"})
 	config.Init()
 	defaultChain := make(map[string]string)
 	defaultChain["default"] = ""
@@ -140,7 +140,12 @@ func (r *TestSchema) URLPatterns() []Route {
 
 func TestNoRefreshSchemaConfig(t *testing.T) {
 	p := os.Getenv("GOPATH")
-	os.Setenv("CHASSIS_HOME", filepath.Join(p, "src", "github.com", "go-chassis", "go-chassis", "examples", "discovery", "server"))
+	home := filepath.Join(p, "src", "github.com", "go-chassis", "go-chassis", "examples", "discovery", "server")
+	// 依赖GOPATH中go-chassis的示例配置
+	if _, err := os.Stat(filepath.Join(home, "conf", "chassis.yaml")); err != nil {
+		t.Skip("go-chassis examples not found in GOPATH")
+	}
+	os.Setenv("CHASSIS_HOME", home)
 	log.Println(os.Getenv("CHASSIS_HOME"))
 	config.Init()
 	assert.Equal(t, true, config.GlobalDefinition.Cse.NoRefreshSchema)
@@ -221,38 +226,42 @@ func (st SchemaTest) URLPatterns() []Route {
 func (st SchemaTest) Handler(ctx *Context) {
 }
 
-func Test_restfulServer_register2GoRestful(t *testing.T) {
-	initEnv()
+// initArchaius 使用内存配置, Register生成处理链时读取路由配置
+func initArchaius() {
+	archaius.Init(archaius.WithMemorySource())
+}
 
-	rest := &restfulServer{
-		microServiceName: "rest",
-		container:        restful.NewContainer(),
-		ws:               new(restful.WebService),
-		server:           &http.Server{},
+func TestRegisterProducesConsumes(t *testing.T) {
+	initLogger()
+	initArchaius()
+	assertRoutes := func(routes []rf.Route) {
+		assert.Equal(t, 4, len(routes), "there should be %d routes", len(schemaTestRoutes))
+		for _, route := range routes {
+			switch route.Path {
+			case "/none":
+				assert.Equal(t, []string{"*/*"}, route.Consumes)
+				assert.Equal(t, []string{"*/*"}, route.Produces)
+			case "/with-produces":
+				assert.Equal(t, schemaTestProduces, route.Produces)
+				assert.Equal(t, []string{"*/*"}, route.Consumes)
+			case "/with-consumes":
+				assert.Equal(t, []string{"*/*"}, route.Produces)
+				assert.Equal(t, schemaTestConsumes, route.Consumes)
+			case "/with-all":
+				assert.Equal(t, schemaTestProduces, route.Produces)
+				assert.Equal(t, schemaTestConsumes, route.Consumes)
+			default:
+				log.Println(route.Path)
+			}
+		}
 	}
 
-	_, err := rest.Register(&SchemaTest{})
+	r := newSwapServer(t)
+	_, err := r.Register(&SchemaTest{})
 	assert.NoError(t, err)
+	assertRoutes(r.ws[0].Routes())
 
-	routes := rest.ws.Routes()
-	assert.Equal(t, 4, len(routes), "there should be %d routes", len(schemaTestRoutes))
-
-	for _, route := range routes {
-		switch route.Path {
-		case "/none":
-			assert.Equal(t, []string{"*/*"}, route.Consumes)
-			assert.Equal(t, []string{"*/*"}, route.Produces)
-		case "/with-produces":
-			assert.Equal(t, schemaTestProduces, route.Produces)
-			assert.Equal(t, []string{"*/*"}, route.Consumes)
-		case "/with-consumes":
-			assert.Equal(t, []string{"*/*"}, route.Produces)
-			assert.Equal(t, schemaTestConsumes, route.Consumes)
-		case "/with-all":
-			assert.Equal(t, schemaTestProduces, route.Produces)
-			assert.Equal(t, schemaTestConsumes, route.Consumes)
-		default:
-			log.Println(route.Path)
-		}
-	}
+	m, err := NewMux(&SchemaTest{})
+	assert.NoError(t, err)
+	assertRoutes(m.ws[0].Routes())
 }
@@ -0,0 +1,117 @@
+package restful
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-chassis/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"google.golang.org/grpc/codes"
+)
+
+// 请求属性, 由Response写入, 用于记录grpc状态码及错误码
+const (
+	attrGrpcCode = "restful.grpc_code"
+	attrErrCode  = "restful.err_code"
+)
+
+// 路由级别的标签, 路径使用路由模板以控制基数
+var routeLabels = []string{"service", "rpc", "version", "method", "path"}
+
+var (
+	requestTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restful_requests_total",
+		Help: "Total number of requests handled by generated routes.",
+	}, append(routeLabels, "code", "grpc_code", "err_code"))
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "restful_request_duration_seconds",
+		Help:    "Latency of requests handled by generated routes.",
+		Buckets: prometheus.DefBuckets,
+	}, append(routeLabels, "code", "grpc_code", "err_code"))
+	requestSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "restful_request_size_bytes",
+		Help:    "Size of request bodies handled by generated routes.",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+	}, routeLabels)
+	responseSize = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "restful_response_size_bytes",
+		Help:    "Size of response bodies written by generated routes.",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+	}, routeLabels)
+	requestInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "restful_requests_in_flight",
+		Help: "Number of requests currently handled by generated routes.",
+	}, routeLabels)
+	panicTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restful_panics_total",
+		Help: "Total number of panics recovered in generated routes.",
+	}, routeLabels)
+)
+
+func init() {
+	// 注册到go-chassis的prometheus registry, 通过metrics接口一并暴露
+	metrics.GetSystemPrometheusRegistry().MustRegister(
+		requestTotal, requestDuration, requestSize, responseSize, requestInFlight, panicTotal)
+}
+
+// setResult 记录请求的grpc状态码及错误码
+func setResult(req *restful.Request, code codes.Code, errCode int) {
+	if req == nil {
+		return
+	}
+	req.SetAttribute(attrGrpcCode, code)
+	req.SetAttribute(attrErrCode, errCode)
+}
+
+// routeObserver 记录单个路由的请求指标
+type routeObserver struct {
+	labels prometheus.Labels
+}
+
+func newRouteObserver(route Route, schemaName string) *routeObserver {
+	return &routeObserver{
+		labels: prometheus.Labels{
+			"service": schemaName,
+			"rpc":     route.ResourceFuncName,
+			"version": route.Version,
+			"method":  route.Method,
+			"path":    routeTemplate(route),
+		},
+	}
+}
+
+// recovered 记录处理请求时恢复的panic
+func (o *routeObserver) recovered() {
+	panicTotal.With(o.labels).Inc()
+}
+
+// begin 请求开始, 返回请求结束时调用的函数
+func (o *routeObserver) begin(req *restful.Request, rep *restful.Response) func() {
+	start := time.Now()
+	requestInFlight.With(o.labels).Inc()
+	if req.Request.ContentLength > 0 {
+		requestSize.With(o.labels).Observe(float64(req.Request.ContentLength))
+	}
+	return func() {
+		requestInFlight.With(o.labels).Dec()
+		responseSize.With(o.labels).Observe(float64(rep.ContentLength()))
+
+		labels := prometheus.Labels{
+			"code":      strconv.Itoa(rep.StatusCode()),
+			"grpc_code": "",
+			"err_code":  "",
+		}
+		if code, ok := req.Attribute(attrGrpcCode).(codes.Code); ok {
+			labels["grpc_code"] = code.String()
+		}
+		if errCode, ok := req.Attribute(attrErrCode).(int); ok {
+			labels["err_code"] = strconv.Itoa(errCode)
+		}
+		for k, v := range o.labels {
+			labels[k] = v
+		}
+		requestTotal.With(labels).Inc()
+		requestDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
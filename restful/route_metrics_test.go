@@ -0,0 +1,37 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+)
+
+func TestRouteObserver(t *testing.T) {
+	route := Route{Method: http.MethodPost, Path: "/hello/{name}", Version: "v1", ResourceFuncName: "Hello"}
+	observer := newRouteObserver(route, "GreeterHttpHandler")
+
+	r, _ := http.NewRequest(http.MethodPost, "/v1/hello/world", strings.NewReader("{}"))
+	req := restful.NewRequest(r)
+	rep := restful.NewResponse(httptest.NewRecorder())
+
+	done := observer.begin(req, rep)
+	assert.Equal(t, float64(1), testutil.ToFloat64(requestInFlight.With(observer.labels)))
+	setResult(req, codes.NotFound, 10001)
+	rep.WriteHeader(http.StatusNotFound)
+	done()
+	assert.Equal(t, float64(0), testutil.ToFloat64(requestInFlight.With(observer.labels)))
+
+	labels := prometheus.Labels{"code": "404", "grpc_code": "NotFound", "err_code": "10001"}
+	for k, v := range observer.labels {
+		labels[k] = v
+	}
+	assert.Equal(t, "/v1/hello/{name}", labels["path"])
+	assert.Equal(t, float64(1), testutil.ToFloat64(requestTotal.With(labels)))
+}
@@ -0,0 +1,274 @@
+package restful
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-archaius/event"
+	"github.com/go-mesh/openlogging"
+)
+
+// 停用路由配置, 配置变化时立即生效
+const RoutesDisabledKey = "cse.restful.routes.disabled" // 逗号分隔的路由, 格式为 "GET /v1/hello"
+
+// routeEntry 路由及包装后的处理函数
+type routeEntry struct {
+	route   Route
+	handler restful.RouteFunction
+}
+
+func (e routeEntry) key() string {
+	return routeKey(e.route.Version, e.route.Path, e.route.Method)
+}
+
+// withEntry 返回添加或替换路由后的副本
+func withEntry(entries []routeEntry, entry routeEntry) []routeEntry {
+	next := make([]routeEntry, 0, len(entries)+1)
+	replaced := false
+	for _, each := range entries {
+		if each.key() == entry.key() {
+			next = append(next, entry)
+			replaced = true
+			continue
+		}
+		next = append(next, each)
+	}
+	if !replaced {
+		next = append(next, entry)
+	}
+	return next
+}
+
+// withoutEntry 返回删除路由后的副本, 路由不存在时返回false
+func withoutEntry(entries []routeEntry, key string) ([]routeEntry, bool) {
+	next := make([]routeEntry, 0, len(entries))
+	for _, each := range entries {
+		if each.key() != key {
+			next = append(next, each)
+		}
+	}
+	return next, len(next) != len(entries)
+}
+
+// registe2WebService 将路由注册到对应版本的webservice中, 版本不存在时新建
+func registe2WebService(wss []*restful.WebService, routeSpec Route, handler restful.RouteFunction) ([]*restful.WebService, error) {
+	ws, err := webServiceByVersion(wss, routeSpec.Version)
+	if err != nil {
+		ws = new(restful.WebService)
+		ws = ws.ApiVersion(routeSpec.Version)
+		ws = ws.Path(routeSpec.Version)
+		wss = append(wss, ws)
+	}
+	var rb *restful.RouteBuilder
+	switch routeSpec.Method {
+	case http.MethodGet:
+		rb = ws.GET(routeSpec.Path)
+	case http.MethodPost:
+		rb = ws.POST(routeSpec.Path)
+	case http.MethodHead:
+		rb = ws.HEAD(routeSpec.Path)
+	case http.MethodPut:
+		rb = ws.PUT(routeSpec.Path)
+	case http.MethodPatch:
+		rb = ws.PATCH(routeSpec.Path)
+	case http.MethodDelete:
+		rb = ws.DELETE(routeSpec.Path)
+	default:
+		return nil, errors.New("method [" + routeSpec.Method + "] do not support")
+	}
+	rb = fillParam(routeSpec, rb)
+
+	for _, r := range routeSpec.Returns {
+		rb = rb.Returns(r.Code, r.Message, r.Model)
+	}
+	if routeSpec.Read != nil {
+		rb = rb.Reads(routeSpec.Read)
+	}
+
+	if len(routeSpec.Consumes) > 0 {
+		rb = rb.Consumes(routeSpec.Consumes...)
+	} else {
+		rb = rb.Consumes("*/*")
+	}
+	if len(routeSpec.Produces) > 0 {
+		rb = rb.Produces(routeSpec.Produces...)
+	} else {
+		rb = rb.Produces("*/*")
+	}
+	ws.Route(rb.To(handler).Doc(routeSpec.FuncDesc).Operation(routeSpec.ResourceFuncName))
+	return wss, nil
+}
+
+// webServiceByVersion 根据不同的route版本选取不同的webservice
+func webServiceByVersion(wss []*restful.WebService, version string) (*restful.WebService, error) {
+	for _, each := range wss {
+		if version != "" {
+			if each.RootPath() == version {
+				return each, nil
+			}
+		} else {
+			if each.RootPath() == version || each.RootPath() == "/" {
+				return each, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("version '%s' webservice not found", version)
+}
+
+// build 根据路由生成新的容器, 已有的容器不做修改
+// 调用方需持有routeMu
+func (r *RestfulServer) build(entries []routeEntry) (*restful.Container, []*restful.WebService, error) {
+	wss := []*restful.WebService{new(restful.WebService)}
+	var err error
+	for _, e := range r.system {
+		if wss, err = registe2WebService(wss, e.route, e.handler); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, e := range entries {
+		if r.disabled[e.key()] {
+			continue
+		}
+		if wss, err = registe2WebService(wss, e.route, e.handler); err != nil {
+			return nil, nil, err
+		}
+	}
+	container := restful.NewContainer()
+	for _, f := range r.filters {
+		container.Filter(f)
+	}
+	for _, ws := range wss {
+		container.Add(ws)
+	}
+	for _, ws := range r.extraWs {
+		container.Add(ws)
+	}
+	return container, wss, nil
+}
+
+// apply 生成新的路由并整体替换, 进行中的请求继续使用旧的路由
+// 调用方需持有routeMu
+func (r *RestfulServer) apply(entries []routeEntry) error {
+	container, wss, err := r.build(entries)
+	if err != nil {
+		return err
+	}
+	r.entries = entries
+	methods := jsonRPCMethods(entries, r.disabled)
+	fields := newGraphQLFields(r.graphqlSchemas, methods)
+	r.mux.Lock()
+	r.container = container
+	r.ws = wss
+	r.rpcMethods = methods
+	r.graphqlFields = fields
+	r.mux.Unlock()
+	for _, ws := range wss {
+		openlogging.GetLogger().Debugf("root path '%s' routes %+v", ws.RootPath(), ws.Routes())
+	}
+	return nil
+}
+
+// ServeHTTP 使用当前的路由处理请求
+func (r *RestfulServer) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	defer r.lifecycle.track()()
+	r.mux.RLock()
+	container := r.container
+	r.mux.RUnlock()
+	container.ServeHTTP(w, req)
+}
+
+// rpcMethodTable 当前的JSON-RPC方法表
+func (r *RestfulServer) rpcMethodTable() map[string]routeEntry {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.rpcMethods
+}
+
+// graphQLFieldTable 当前的GraphQL字段表
+func (r *RestfulServer) graphQLFieldTable() *graphQLFields {
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	return r.graphqlFields
+}
+
+// keepExtraWebServices 保留直接注册到容器中的webservice, 如swagger, 重新生成路由时继续注册
+func (r *RestfulServer) keepExtraWebServices() {
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+	own := make(map[*restful.WebService]bool)
+	for _, ws := range r.ws {
+		own[ws] = true
+	}
+	r.extraWs = nil
+	for _, ws := range r.container.RegisteredWebServices() {
+		if !own[ws] {
+			r.extraWs = append(r.extraWs, ws)
+		}
+	}
+}
+
+// SetRouteDisabled 停用或启用路由, 停用的路由返回404
+// 运行时的设置在停用路由配置变化后以配置为准
+// @version 路由版本
+// @path 路由路径
+// @method 路由方式
+func (r *RestfulServer) SetRouteDisabled(version, path, method string, disabled bool) error {
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	key := routeKey(version, path, method)
+	if r.disabled[key] == disabled {
+		return nil
+	}
+	next := make(map[string]bool, len(r.disabled)+1)
+	for k, v := range r.disabled {
+		next[k] = v
+	}
+	next[key] = disabled
+	return r.applyDisabled(next)
+}
+
+// applyDisabled 替换停用的路由并重新生成路由, 失败时保持原状
+// 调用方需持有routeMu
+func (r *RestfulServer) applyDisabled(disabled map[string]bool) error {
+	old := r.disabled
+	r.disabled = disabled
+	if err := r.apply(r.entries); err != nil {
+		r.disabled = old
+		return err
+	}
+	return nil
+}
+
+// routeSwitch 停用路由配置变化时重新生成路由
+type routeSwitch struct {
+	r *RestfulServer
+}
+
+// Event 配置变化时重新加载停用的路由
+func (s routeSwitch) Event(e *event.Event) {
+	openlogging.GetLogger().Infof("disabled routes config '%s' changed, reload", e.Key)
+	s.r.loadDisabledRoutes()
+}
+
+// loadDisabledRoutes 从配置中加载停用的路由
+func (r *RestfulServer) loadDisabledRoutes() {
+	disabled := make(map[string]bool)
+	for _, route := range splitList(archaius.GetString(RoutesDisabledKey, "")) {
+		if fields := strings.Fields(route); len(fields) == 2 {
+			disabled[routeKey("", fields[1], fields[0])] = true
+		} else {
+			openlogging.GetLogger().Warnf("invalid disabled route '%s'", route)
+		}
+	}
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	if err := r.applyDisabled(disabled); err != nil {
+		openlogging.GetLogger().Errorf("reload disabled routes failed: %s", err.Error())
+	}
+}
@@ -0,0 +1,113 @@
+package restful
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func okHandler(req *restful.Request, rep *restful.Response) {
+	rep.WriteHeader(http.StatusOK)
+}
+
+func newSwapServer(t *testing.T, entries ...routeEntry) *RestfulServer {
+	r := &RestfulServer{routes: newRouteTable()}
+	assert.NoError(t, r.apply(entries))
+	return r
+}
+
+func serve(r *RestfulServer, method, path string) int {
+	req, _ := http.NewRequest(method, path, nil)
+	rw := httptest.NewRecorder()
+	r.ServeHTTP(rw, req)
+	return rw.Code
+}
+
+// addEntry 与AddRoute相同的替换流程, 跳过处理链的包装
+func addEntry(r *RestfulServer, route Route, handler restful.RouteFunction) error {
+	r.routeMu.Lock()
+	defer r.routeMu.Unlock()
+	return r.apply(withEntry(r.entries, routeEntry{route: route, handler: handler}))
+}
+
+func TestHotRoutes(t *testing.T) {
+	r := newSwapServer(t, routeEntry{route: Route{Method: http.MethodGet, Path: "/stable", Version: "v1"}, handler: okHandler})
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/v3/hot"))
+
+	// 启动后新增的版本同样生效
+	assert.NoError(t, addEntry(r, Route{Method: http.MethodGet, Path: "/hot", Version: "v3"}, okHandler))
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/v3/hot"))
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/v1/stable"))
+
+	assert.NoError(t, r.DelRoute("v3", "/hot", http.MethodGet))
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/v3/hot"))
+	assert.Equal(t, ErrRouteNotFound, r.DelRoute("v3", "/hot", http.MethodGet))
+
+	assert.NoError(t, r.SetRouteDisabled("v1", "/stable", http.MethodGet, true))
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/v1/stable"))
+	assert.NoError(t, r.SetRouteDisabled("v1", "/stable", http.MethodGet, false))
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/v1/stable"))
+
+	// 路由错误时保持原有路由
+	assert.Error(t, addEntry(r, Route{Method: "TRACE", Path: "/bad", Version: "v1"}, okHandler))
+	assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/v1/stable"))
+}
+
+func TestHotRoutesInFlight(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	slow := func(req *restful.Request, rep *restful.Response) {
+		close(started)
+		<-release
+		rep.WriteHeader(http.StatusOK)
+	}
+	r := newSwapServer(t, routeEntry{route: Route{Method: http.MethodGet, Path: "/slow", Version: "v1"}, handler: slow})
+	done := make(chan int)
+	go func() {
+		done <- serve(r, http.MethodGet, "/v1/slow")
+	}()
+	<-started
+	assert.NoError(t, r.DelRoute("v1", "/slow", http.MethodGet))
+	assert.Equal(t, http.StatusNotFound, serve(r, http.MethodGet, "/v1/slow"))
+	close(release)
+	assert.Equal(t, http.StatusOK, <-done)
+}
+
+func TestHotRoutesConcurrent(t *testing.T) {
+	r := newSwapServer(t, routeEntry{route: Route{Method: http.MethodGet, Path: "/stable", Version: "v1"}, handler: okHandler})
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				assert.Equal(t, http.StatusOK, serve(r, http.MethodGet, "/v1/stable"))
+				code := serve(r, http.MethodGet, "/v2/hot")
+				assert.True(t, code == http.StatusOK || code == http.StatusNotFound, code)
+				r.GetWebService()
+			}
+		}()
+	}
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for i := 0; time.Now().Before(deadline); i++ {
+		if i%2 == 0 {
+			assert.NoError(t, addEntry(r, Route{Method: http.MethodGet, Path: "/hot", Version: "v2"}, okHandler))
+		} else {
+			assert.NoError(t, r.DelRoute("v2", "/hot", http.MethodGet))
+		}
+		assert.NoError(t, r.SetRouteDisabled("v2", "/other", http.MethodGet, i%3 == 0))
+	}
+	close(stop)
+	wg.Wait()
+}
@@ -0,0 +1,170 @@
+package restful
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/emicklei/go-restful"
+)
+
+// 路由表管理接口配置
+const (
+	RoutesAdminEnableKey   = "cse.restful.admin.routes.enable"
+	RoutesAdminPathKey     = "cse.restful.admin.routes.path"
+	DefaultRoutesAdminPath = "/admin/routes"
+)
+
+// ErrRouteNotFound 路由未注册
+var ErrRouteNotFound = errors.New("route not found")
+
+// RouteInfo 注册的路由及所属schema
+type RouteInfo struct {
+	Schema string
+	Route  Route
+}
+
+// RouteFilter 路由过滤条件
+type RouteFilter func(info RouteInfo) bool
+
+// WithRouteVersion 过滤路由版本
+func WithRouteVersion(version string) RouteFilter {
+	return func(info RouteInfo) bool {
+		return info.Route.Version == version
+	}
+}
+
+// WithRouteSchema 过滤路由所属schema
+func WithRouteSchema(schema string) RouteFilter {
+	return func(info RouteInfo) bool {
+		return info.Schema == schema
+	}
+}
+
+// WithRouteMetadata 过滤设置了指定元数据的路由
+func WithRouteMetadata(key string) RouteFilter {
+	return func(info RouteInfo) bool {
+		_, ok := info.Route.Metadata[key]
+		return ok
+	}
+}
+
+// routeTable 服务注册的路由
+type routeTable struct {
+	mu     sync.RWMutex
+	routes map[string]RouteInfo
+}
+
+func newRouteTable() *routeTable {
+	return &routeTable{routes: make(map[string]RouteInfo)}
+}
+
+func (t *routeTable) add(schema string, route Route) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.routes[routeKey(route.Version, route.Path, route.Method)] = RouteInfo{Schema: schema, Route: route}
+}
+
+func (t *routeTable) del(version, path, method string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.routes, routeKey(version, path, method))
+}
+
+func (t *routeTable) get(version, path, method string) (RouteInfo, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	info, ok := t.routes[routeKey(version, path, method)]
+	return info, ok
+}
+
+// list 按版本、路径及方法排序返回满足所有过滤条件的路由
+func (t *routeTable) list(filters ...RouteFilter) []RouteInfo {
+	t.mu.RLock()
+	infos := make([]RouteInfo, 0, len(t.routes))
+	for _, info := range t.routes {
+		infos = append(infos, info)
+	}
+	t.mu.RUnlock()
+	var matched []RouteInfo
+	for _, info := range infos {
+		ok := true
+		for _, filter := range filters {
+			if !filter(info) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			matched = append(matched, info)
+		}
+	}
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i].Route, matched[j].Route
+		if a.Version != b.Version {
+			return a.Version < b.Version
+		}
+		if a.Path != b.Path {
+			return a.Path < b.Path
+		}
+		return a.Method < b.Method
+	})
+	return matched
+}
+
+// GetRoute 获取路由, 未注册时返回ErrRouteNotFound
+// @version 路由版本
+// @path 路由路径
+// @method 路由方式
+func (r *RestfulServer) GetRoute(version, path, method string) (Route, error) {
+	info, ok := r.routes.get(version, path, method)
+	if !ok {
+		return Route{}, ErrRouteNotFound
+	}
+	return info.Route, nil
+}
+
+// ListRoutes 列出注册的路由
+func (r *RestfulServer) ListRoutes(filters ...RouteFilter) []RouteInfo {
+	return r.routes.list(filters...)
+}
+
+// routeView 管理接口中路由的json格式
+type routeView struct {
+	Schema   string            `json:"schema"`
+	Method   string            `json:"method"`
+	Path     string            `json:"path"`
+	Version  string            `json:"version"`
+	Func     string            `json:"func"`
+	Desc     string            `json:"desc,omitempty"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// handleRoutes 以json返回路由表, 支持version、schema及metadata查询参数过滤
+func (r *RestfulServer) handleRoutes(req *restful.Request, rep *restful.Response) {
+	var filters []RouteFilter
+	query := req.Request.URL.Query()
+	if _, ok := query["version"]; ok {
+		filters = append(filters, WithRouteVersion(query.Get("version")))
+	}
+	if schema := query.Get("schema"); schema != "" {
+		filters = append(filters, WithRouteSchema(schema))
+	}
+	if key := query.Get("metadata"); key != "" {
+		filters = append(filters, WithRouteMetadata(key))
+	}
+	views := make([]routeView, 0)
+	for _, info := range r.ListRoutes(filters...) {
+		views = append(views, routeView{
+			Schema:   info.Schema,
+			Method:   info.Route.Method,
+			Path:     routeTemplate(info.Route),
+			Version:  info.Route.Version,
+			Func:     info.Route.ResourceFuncName,
+			Desc:     info.Route.FuncDesc,
+			Metadata: info.Route.Metadata,
+		})
+	}
+	rep.WriteHeaderAndJson(http.StatusOK, views, "application/json;charset=utf-8")
+}
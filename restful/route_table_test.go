@@ -0,0 +1,65 @@
+package restful
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRouteTableServer() *RestfulServer {
+	r := &RestfulServer{routes: newRouteTable()}
+	r.routes.add("HelloHttpHandler", Route{Method: http.MethodGet, Path: "/hello/{name}", Version: "v1", ResourceFuncName: "Hello",
+		Metadata: map[string]string{TIMEOUT_METADATA: "5s"}})
+	r.routes.add("HelloHttpHandler", Route{Method: http.MethodPost, Path: "/hello", Version: "v1", ResourceFuncName: "Create"})
+	r.routes.add("UserHttpHandler", Route{Method: http.MethodGet, Path: "/users", Version: "v2", ResourceFuncName: "List"})
+	return r
+}
+
+func TestGetRoute(t *testing.T) {
+	r := newRouteTableServer()
+	route, err := r.GetRoute("v1", "/hello/{name}", "get")
+	assert.NoError(t, err)
+	assert.Equal(t, "Hello", route.ResourceFuncName)
+
+	_, err = r.GetRoute("v2", "/hello/{name}", http.MethodGet)
+	assert.Equal(t, ErrRouteNotFound, err)
+
+	r.routes.del("v1", "/hello/{name}", http.MethodGet)
+	_, err = r.GetRoute("v1", "/hello/{name}", http.MethodGet)
+	assert.Equal(t, ErrRouteNotFound, err)
+}
+
+func TestListRoutes(t *testing.T) {
+	r := newRouteTableServer()
+	routes := r.ListRoutes()
+	assert.Len(t, routes, 3)
+	assert.Equal(t, "Create", routes[0].Route.ResourceFuncName)
+	assert.Equal(t, "Hello", routes[1].Route.ResourceFuncName)
+
+	routes = r.ListRoutes(WithRouteVersion("v1"), WithRouteMetadata(TIMEOUT_METADATA))
+	assert.Len(t, routes, 1)
+	assert.Equal(t, "Hello", routes[0].Route.ResourceFuncName)
+
+	routes = r.ListRoutes(WithRouteSchema("UserHttpHandler"))
+	assert.Len(t, routes, 1)
+	assert.Equal(t, "v2", routes[0].Route.Version)
+
+	assert.Empty(t, r.ListRoutes(WithRouteSchema("Unknown")))
+}
+
+func TestHandleRoutes(t *testing.T) {
+	r := newRouteTableServer()
+	req, _ := http.NewRequest(http.MethodGet, DefaultRoutesAdminPath+"?schema=HelloHttpHandler", nil)
+	rw := httptest.NewRecorder()
+	r.handleRoutes(restful.NewRequest(req), restful.NewResponse(rw))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	var views []routeView
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &views))
+	assert.Len(t, views, 2)
+	assert.Equal(t, routeView{Schema: "HelloHttpHandler", Method: http.MethodPost, Path: "/v1/hello", Version: "v1", Func: "Create"}, views[0])
+	assert.Equal(t, "5s", views[1].Metadata[TIMEOUT_METADATA])
+}
@@ -1,9 +1,12 @@
 package restful
 
 import (
+	"context"
 	"fmt"
 	"net/http"
+	"path"
 	"reflect"
+	"time"
 
 	"github.com/emicklei/go-restful"
 	"github.com/go-chassis/go-chassis/core/common"
@@ -72,17 +75,63 @@ func GetRouteSpecs(schema interface{}) ([]Route, error) {
 	return []Route{}, fmt.Errorf("<rest.RegisterResource> result of 'URLPatterns' function not []*Route type in servant struct `%s`", name)
 }
 
+// routeTemplate 返回包含路由版本的路径模板
+func routeTemplate(route Route) string {
+	return path.Join("/", route.Version, route.Path)
+}
+
 //WrapHandlerChain wrap business handler with handler chain
 func WrapHandlerChain(route Route, schemaType reflect.Type, schemaValue reflect.Value, schemaName string,
 	opts server.Options) (restful.RouteFunction, error) {
+	return wrapHandlerChain(route, schemaType, schemaValue, schemaName, opts, nil)
+}
+
+// wrapHandlerChain 维护模式在指标及访问日志之内检查, 拒绝的请求同样被记录
+func wrapHandlerChain(route Route, schemaType reflect.Type, schemaValue reflect.Value, schemaName string,
+	opts server.Options, m *maintenance) (restful.RouteFunction, error) {
 	openlogging.GetLogger().Infof("add route path: [%s] method: [%s] func: [%s]. ", route.Path, route.Method, route.ResourceFuncName)
-	method, exist := schemaType.MethodByName(route.ResourceFuncName)
-	if !exist {
-		openlogging.GetLogger().Errorf("router func can not find: %s", route.ResourceFuncName)
-		return nil, fmt.Errorf("router func can not find: %s", route.ResourceFuncName)
+	call, err := newRouteCall(route, schemaType, schemaValue)
+	if err != nil {
+		return nil, err
 	}
 
+	limiter, err := newRateLimiter(route)
+	if err != nil {
+		openlogging.GetLogger().Errorf("route rate limit parse failed: %s", err.Error())
+		return nil, err
+	}
+	observer := newRouteObserver(route, schemaName)
+	accessLog := newAccessLogger(route)
+	compressor := newCompressor()
+
 	handler := func(req *restful.Request, rep *restful.Response) {
+		defer observer.begin(req, rep)()
+		defer accessLog.begin(req, rep)()
+		spanCtx, span := startServerSpan(req.Request, route, schemaName)
+		defer func() {
+			endServerSpan(span, rep.StatusCode())
+		}()
+		req.Request = req.Request.WithContext(spanCtx)
+		// 在recover之前关闭, panic时的错误响应也会被压缩
+		if w := compressor.wrap(req, rep); w != nil {
+			defer w.Close()
+		}
+		defer recoverHandler(req, rep, route, observer)
+		if !m.allow(route, req, rep) {
+			return
+		}
+		if !limiter.allow(req, rep) {
+			return
+		}
+		// 解压后再由BodyLimit限制请求体大小
+		if err := decompressRequest(req.Request); err != nil {
+			Response(newContext(req, rep), nil, err)
+			return
+		}
+		if opts.BodyLimit > 0 && req.Request.ContentLength > opts.BodyLimit {
+			Response(newContext(req, rep), nil, ErrBodyTooLarge)
+			return
+		}
 		c, err := handler.GetChain(common.Provider, opts.ChainName)
 		if err != nil {
 			openlogging.GetLogger().Errorf("handler chain init err [%s]", err.Error())
@@ -90,7 +139,7 @@ func WrapHandlerChain(route Route, schemaType reflect.Type, schemaValue reflect.
 			rep.WriteErrorString(http.StatusInternalServerError, err.Error())
 			return
 		}
-		inv, err := HTTPRequest2Invocation(req, schemaName, method.Name)
+		inv, err := HTTPRequest2Invocation(req, schemaName, call.method.Name)
 		if err != nil {
 			openlogging.GetLogger().Errorf("transfer http request to invocation failed, err [%s]", err.Error())
 			return
@@ -105,18 +154,12 @@ func WrapHandlerChain(route Route, schemaType reflect.Type, schemaValue reflect.
 			}
 			Invocation2HTTPRequest(inv, req)
 
-			bs := NewBaseServer(inv.Ctx)
-			bs.Req = req
-			bs.Resp = rep
-			ir.Status = bs.Resp.StatusCode()
-			// check body size
-			if opts.BodyLimit > 0 {
-				bs.Req.Request.Body = http.MaxBytesReader(bs.Resp, bs.Req.Request.Body, opts.BodyLimit)
-			}
-			method.Func.Call([]reflect.Value{schemaValue, reflect.ValueOf(bs)})
+			ir.Status = rep.StatusCode()
+			// inv.Ctx may be replaced by handlers in chain, use the final one
+			call.call(inv.Ctx, req, rep, opts.BodyLimit)
 
-			if bs.Resp.StatusCode() >= http.StatusBadRequest {
-				return fmt.Errorf("get err from http handle, get status: %d", bs.Resp.StatusCode())
+			if rep.StatusCode() >= http.StatusBadRequest {
+				return fmt.Errorf("get err from http handle, get status: %d", rep.StatusCode())
 			}
 			return nil
 		})
@@ -125,3 +168,68 @@ func WrapHandlerChain(route Route, schemaType reflect.Type, schemaValue reflect.
 
 	return handler, nil
 }
+
+// routeCall 调用路由对应的业务函数, 不经过go-chassis处理链
+type routeCall struct {
+	method         reflect.Method
+	schemaValue    reflect.Value
+	timeout        time.Duration
+	bodyBuffer     int64
+	upload         *uploadSpec
+	etag           *etagSpec
+	fieldsReserved bool
+}
+
+// newRouteCall 查找路由的业务函数并解析路由元数据
+func newRouteCall(route Route, schemaType reflect.Type, schemaValue reflect.Value) (*routeCall, error) {
+	method, exist := schemaType.MethodByName(route.ResourceFuncName)
+	if !exist {
+		openlogging.GetLogger().Errorf("router func can not find: %s", route.ResourceFuncName)
+		return nil, fmt.Errorf("router func can not find: %s", route.ResourceFuncName)
+	}
+	timeout, err := routeTimeout(route)
+	if err != nil {
+		openlogging.GetLogger().Errorf("route timeout parse failed: %s", err.Error())
+		return nil, err
+	}
+	bodyBuffer, err := routeBodyBuffer(route)
+	if err != nil {
+		openlogging.GetLogger().Errorf("route buffer body parse failed: %s", err.Error())
+		return nil, err
+	}
+	upload, err := routeUpload(route)
+	if err != nil {
+		openlogging.GetLogger().Errorf("route upload parse failed: %s", err.Error())
+		return nil, err
+	}
+	return &routeCall{
+		method:         method,
+		schemaValue:    schemaValue,
+		timeout:        timeout,
+		bodyBuffer:     bodyBuffer,
+		upload:         upload,
+		etag:           routeETag(route),
+		fieldsReserved: routeFieldsReserved(route),
+	}, nil
+}
+
+// call 使用派生自parent的上下文调用业务函数
+// parent需派生自请求的上下文
+func (c *routeCall) call(parent context.Context, req *restful.Request, rep *restful.Response, bodyLimit int64) {
+	ctx, cancel := newRequestContext(parent, req.Request, c.timeout)
+	defer cancel()
+	ctx = preconditionContext(ctx, req.Request)
+	req.Request = req.Request.WithContext(ctx)
+	bs := NewBaseServer(ctx)
+	bs.Req = req
+	bs.Resp = rep
+	bs.bodyBuffer = c.bodyBuffer
+	bs.upload = c.upload
+	bs.etag = c.etag
+	bs.fieldsReserved = c.fieldsReserved
+	bs.rpc = rpcProtocolFrom(ctx)
+	defer bs.removeTempFiles()
+	// check body size
+	limitBody(bs.Req.Request, bodyLimit)
+	c.method.Func.Call([]reflect.Value{c.schemaValue, reflect.ValueOf(bs)})
+}
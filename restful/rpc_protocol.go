@@ -0,0 +1,393 @@
+package restful
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-mesh/openlogging"
+	"github.com/golang/protobuf/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// gRPC-Web、Connect、JSON-RPC及GraphQL的协议类型
+const (
+	rpcGrpcWeb = iota + 1
+	rpcConnectUnary
+	rpcConnectStream
+	rpcJSONRPC
+	rpcGraphQL
+)
+
+// 消息帧的标记
+const (
+	rpcFrameCompressed  byte = 0x01
+	connectFrameEnd     byte = 0x02
+	grpcWebFrameTrailer byte = 0x80
+)
+
+// connectCodes gRPC状态码对应的Connect错误码
+var connectCodes = map[codes.Code]string{
+	codes.Canceled:           "canceled",
+	codes.Unknown:            "unknown",
+	codes.InvalidArgument:    "invalid_argument",
+	codes.DeadlineExceeded:   "deadline_exceeded",
+	codes.NotFound:           "not_found",
+	codes.AlreadyExists:      "already_exists",
+	codes.PermissionDenied:   "permission_denied",
+	codes.ResourceExhausted:  "resource_exhausted",
+	codes.FailedPrecondition: "failed_precondition",
+	codes.Aborted:            "aborted",
+	codes.OutOfRange:         "out_of_range",
+	codes.Unimplemented:      "unimplemented",
+	codes.Internal:           "internal",
+	codes.Unavailable:        "unavailable",
+	codes.DataLoss:           "data_loss",
+	codes.Unauthenticated:    "unauthenticated",
+}
+
+// connectError Connect协议的错误
+type connectError struct {
+	Code    string `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+// connectEnd Connect流式响应的结束帧
+type connectEnd struct {
+	Error *connectError `json:"error,omitempty"`
+}
+
+type rpcProtocolKey struct{}
+
+// rpcProtocol 一次gRPC-Web、Connect、JSON-RPC或GraphQL调用的编解码及响应状态
+type rpcProtocol struct {
+	kind int
+	json bool
+	// 响应的内容类型, 与请求相同
+	contentType string
+	// 已写入响应头
+	started bool
+	// 已发送响应消息
+	sent bool
+	// 已结束响应
+	finished bool
+	// JSON-RPC及GraphQL调用的参数, 结果及错误不写入响应而是暂存在这
+	params json.RawMessage
+	result interface{}
+	err    error
+}
+
+// newRPCProtocol 根据Content-Type识别协议, 不支持时返回错误
+func newRPCProtocol(req *http.Request) (*rpcProtocol, error) {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get(Header_content_type))
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type: %s", err.Error())
+	}
+	p := &rpcProtocol{contentType: mediaType}
+	switch mediaType {
+	case "application/grpc-web", "application/grpc-web+proto":
+		p.kind = rpcGrpcWeb
+	case "application/grpc-web+json":
+		p.kind, p.json = rpcGrpcWeb, true
+	case "application/proto":
+		p.kind = rpcConnectUnary
+	case "application/json":
+		p.kind, p.json = rpcConnectUnary, true
+	case "application/connect+proto":
+		p.kind = rpcConnectStream
+	case "application/connect+json":
+		p.kind, p.json = rpcConnectStream, true
+	default:
+		return nil, fmt.Errorf("unsupported content type '%s'", mediaType)
+	}
+	return p, nil
+}
+
+// rpcProtocolFrom 获取请求的协议, 普通http请求返回nil
+func rpcProtocolFrom(ctx context.Context) *rpcProtocol {
+	p, _ := ctx.Value(rpcProtocolKey{}).(*rpcProtocol)
+	return p
+}
+
+// withRPCRoutes 为带有rpc_method元数据的路由添加 POST /<package>.<Service>/<Method> 路由
+// 同一方法的多个版本只添加一次
+func withRPCRoutes(routes []Route) []Route {
+	next := append([]Route(nil), routes...)
+	seen := make(map[string]bool)
+	for _, route := range routes {
+		method := route.Metadata[RPC_METHOD_METADATA]
+		if method == "" || seen[method] {
+			continue
+		}
+		seen[method] = true
+		rpc := route
+		rpc.Method = http.MethodPost
+		rpc.Path = method
+		rpc.Version = ""
+		rpc.Parameters = nil
+		next = append(next, rpc)
+	}
+	return next
+}
+
+// isRPCRoute 是否为gRPC-Web及Connect路由
+func isRPCRoute(route Route) bool {
+	return route.Path != "" && route.Path == route.Metadata[RPC_METHOD_METADATA]
+}
+
+// rpcHandler 识别请求的协议后调用handler, 请求及响应由Context按协议编解码
+// JSON-RPC及GraphQL调用已带有协议, 不再识别
+func rpcHandler(handler restful.RouteFunction) restful.RouteFunction {
+	return func(req *restful.Request, rep *restful.Response) {
+		if rpcProtocolFrom(req.Request.Context()) != nil {
+			handler(req, rep)
+			return
+		}
+		p, err := newRPCProtocol(req.Request)
+		if err != nil {
+			rep.AddHeader(Header_content_type, "text/plain")
+			rep.WriteErrorString(http.StatusUnsupportedMediaType, err.Error())
+			return
+		}
+		req.Request = req.Request.WithContext(context.WithValue(req.Request.Context(), rpcProtocolKey{}, p))
+		handler(req, rep)
+	}
+}
+
+// read 按协议解码请求消息
+func (p *rpcProtocol) read(bs *Context, schema interface{}) error {
+	m, ok := schema.(proto.Message)
+	if !ok {
+		return fmt.Errorf("rpc request %T is not a proto message", schema)
+	}
+	switch p.kind {
+	case rpcJSONRPC:
+		return p.readParams(schema)
+	case rpcGraphQL:
+		return p.readArgs(m)
+	}
+	if bs.Req.Request.Body == nil {
+		bs.Req.Request.Body = http.NoBody
+	}
+	body, err := ioutil.ReadAll(bs.Req.Request.Body)
+	if err != nil {
+		return err
+	}
+	if p.kind != rpcConnectUnary {
+		if body, err = p.unframe(bs.Req.Request, body); err != nil {
+			return status.Errorf(codes.InvalidArgument, "(%d)invalid request body: %s", INVALID_REQUEST_BODY_ERR, err.Error())
+		}
+	}
+	if p.json {
+		err = protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(body, proto.MessageV2(m))
+	} else {
+		err = proto.Unmarshal(body, m)
+	}
+	if err != nil {
+		return status.Errorf(codes.InvalidArgument, "(%d)invalid request body: %s", INVALID_REQUEST_BODY_ERR, err.Error())
+	}
+	return nil
+}
+
+// readParams 以json解码JSON-RPC调用的参数, 与http路由的请求体相同, 参数为空时不解码
+func (p *rpcProtocol) readParams(schema interface{}) error {
+	if len(p.params) == 0 || string(p.params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(p.params, schema); err != nil {
+		return status.Errorf(codes.InvalidArgument, "(%d)invalid request body: %s", INVALID_REQUEST_BODY_ERR, err.Error())
+	}
+	return nil
+}
+
+// readArgs 以protojson解码GraphQL字段的参数, 参数名为proto字段名, 枚举及64位整数与json编码一致
+func (p *rpcProtocol) readArgs(m proto.Message) error {
+	if err := protojson.Unmarshal(p.params, proto.MessageV2(m)); err != nil {
+		return status.Errorf(codes.InvalidArgument, "(%d)invalid arguments: %s", INVALID_GRAPHQL_BODY_ERR, err.Error())
+	}
+	return nil
+}
+
+// captured JSON-RPC及GraphQL调用的结果及错误暂存在rpcProtocol中, 不写入响应
+func (p *rpcProtocol) captured() bool {
+	return p.kind == rpcJSONRPC || p.kind == rpcGraphQL
+}
+
+// unframe 解析请求体中唯一的消息帧, 压缩的消息只支持gzip
+func (p *rpcProtocol) unframe(req *http.Request, body []byte) ([]byte, error) {
+	if len(body) < 5 {
+		return nil, errors.New("incomplete message frame")
+	}
+	flags := body[0]
+	data := body[5:]
+	if uint64(binary.BigEndian.Uint32(body[1:5])) != uint64(len(data)) {
+		return nil, errors.New("message frame length mismatch")
+	}
+	if flags&rpcFrameCompressed == 0 {
+		return data, nil
+	}
+	encoding := req.Header.Get(Header_grpc_encoding)
+	if p.kind == rpcConnectStream {
+		encoding = req.Header.Get(Header_connect_content_encoding)
+	}
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported message encoding '%s'", encoding)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// marshal 按协议编码响应消息, 响应为空时编码为空消息
+func (p *rpcProtocol) marshal(msg interface{}) ([]byte, error) {
+	if msg == nil {
+		if p.json {
+			return []byte("{}"), nil
+		}
+		return nil, nil
+	}
+	m, ok := msg.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("rpc response %T is not a proto message", msg)
+	}
+	if p.json {
+		return protojson.Marshal(proto.MessageV2(m))
+	}
+	return proto.Marshal(m)
+}
+
+// start 写入响应头, 只写入一次
+func (p *rpcProtocol) start(b *Context) {
+	if p.started || p.captured() {
+		return
+	}
+	p.started = true
+	b.Resp.Header().Set(Header_content_type, p.contentType)
+	b.WriteHeader(http.StatusOK)
+}
+
+func (p *rpcProtocol) writeFrame(b *Context, flags byte, data []byte) error {
+	frame := make([]byte, 5, 5+len(data))
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	_, err := b.Resp.Write(append(frame, data...))
+	return err
+}
+
+// send 发送一个响应消息, Connect一元请求及JSON-RPC、GraphQL调用只能发送一个消息
+// GraphQL按子字段裁剪结果, 暂存原始消息
+func (p *rpcProtocol) send(b *Context, msg interface{}) error {
+	if p.captured() {
+		if p.sent {
+			return errors.New("rpc result already sent")
+		}
+		p.sent = true
+		p.result = msg
+		if p.kind == rpcJSONRPC {
+			p.result = jsonEntity(msg)
+		}
+		return nil
+	}
+	data, err := p.marshal(msg)
+	if err != nil {
+		openlogging.GetLogger().Errorf("marshal rpc response failed: %s", err.Error())
+		return ErrInternal
+	}
+	if p.kind == rpcConnectUnary {
+		if p.sent {
+			return errors.New("connect unary response already sent")
+		}
+		p.start(b)
+		p.sent = true
+		_, err = b.Resp.Write(data)
+		return err
+	}
+	p.start(b)
+	p.sent = true
+	if err := p.writeFrame(b, 0, data); err != nil {
+		return err
+	}
+	b.Resp.Flush()
+	return b.Ctx.Err()
+}
+
+// respond 以协议的格式响应Response的结果
+func (p *rpcProtocol) respond(b *Context, resp interface{}, err error) {
+	if err == nil {
+		writeOutHead(b, resp)
+		err = p.send(b, resp)
+	}
+	p.finish(b, err)
+}
+
+// finish 结束响应, 错误与http路由相同方式解析
+// gRPC-Web以trailer帧、Connect流式以结束帧返回状态, Connect一元请求出错时以json错误响应
+// JSON-RPC及GraphQL调用只暂存错误
+func (p *rpcProtocol) finish(b *Context, err error) {
+	statusCode, errCode, formatErr := formatError(err)
+	traceResponse(b.Ctx, statusCode, errCode)
+	setResult(b.Req, statusCode, errCode)
+	p.finished = true
+	if p.captured() {
+		p.err = err
+		return
+	}
+	var cerr *connectError
+	if err != nil {
+		openlogging.GetLogger().Errorf("rpc request on '%s' got error[%s]", b.ReadRequest().URL.String(), err.Error())
+		cerr = &connectError{Code: connectCodes[statusCode], Message: status.Convert(formatErr).Message()}
+	}
+	switch p.kind {
+	case rpcGrpcWeb:
+		p.start(b)
+		var message string
+		if cerr != nil {
+			message = encodeGrpcMessage(cerr.Message)
+		}
+		trailer := fmt.Sprintf("%s: %d\r\n%s: %s\r\n", Header_grpc_status, statusCode, Header_grpc_message, message)
+		p.writeFrame(b, grpcWebFrameTrailer, []byte(trailer))
+	case rpcConnectStream:
+		p.start(b)
+		data, _ := json.Marshal(connectEnd{Error: cerr})
+		p.writeFrame(b, connectFrameEnd, data)
+	default:
+		if cerr == nil {
+			if !p.sent {
+				p.send(b, nil)
+			}
+			return
+		}
+		if p.started {
+			return
+		}
+		p.started = true
+		b.WriteHeaderAndJSON(httpStatusFromError(b, statusCode, errCode), cerr, "application/json")
+	}
+}
+
+// encodeGrpcMessage 按gRPC规范对grpc-message进行百分号编码
+func encodeGrpcMessage(msg string) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+		if c >= ' ' && c <= '~' && c != '%' {
+			buf.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&buf, "%%%02X", c)
+	}
+	return buf.String()
+}
@@ -0,0 +1,188 @@
+package restful
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/genproto/googleapis/api/httpbody"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// rpcGreeter 模拟生成的HttpHandler, 路由带有rpc_method元数据
+type rpcGreeter struct{}
+
+func (h *rpcGreeter) URLPatterns() []Route {
+	return []Route{
+		{Method: http.MethodPost, Path: "/hello", Version: "v1", ResourceFuncName: "Hello",
+			Metadata: map[string]string{RPC_METHOD_METADATA: "/gwtest.Greeter/Hello"}},
+		{Method: http.MethodPost, Path: "/hello", Version: "v2", ResourceFuncName: "Hello",
+			Metadata: map[string]string{RPC_METHOD_METADATA: "/gwtest.Greeter/Hello"}},
+		{Method: http.MethodGet, Path: "/export", Version: "v1", ResourceFuncName: "Export",
+			Metadata: map[string]string{RPC_METHOD_METADATA: "/gwtest.Greeter/Export"}},
+	}
+}
+
+func (h *rpcGreeter) Hello(ctx *Context) {
+	var req wrapperspb.StringValue
+	if err := ctx.Read(&req); err != nil {
+		Response(ctx, nil, err)
+		return
+	}
+	if req.GetValue() == "" {
+		Response(ctx, nil, status.Errorf(codes.NotFound, "(%d)not found", 10001))
+		return
+	}
+	Response(ctx, &wrapperspb.StringValue{Value: "hello " + req.GetValue()}, nil)
+}
+
+func (h *rpcGreeter) Export(ctx *Context) {
+	var req wrapperspb.StringValue
+	if err := ctx.Read(&req); err != nil {
+		Response(ctx, nil, err)
+		return
+	}
+	stream := NewHttpBodyStream(ctx)
+	stream.Send(&httpbody.HttpBody{Data: []byte("a")})
+	stream.Send(&httpbody.HttpBody{Data: []byte("b")})
+	stream.Close(nil)
+}
+
+func rpcFrame(flags byte, data []byte) []byte {
+	frame := make([]byte, 5)
+	frame[0] = flags
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(data)))
+	return append(frame, data...)
+}
+
+// readRPCFrames 依次解析响应中的消息帧
+func readRPCFrames(t *testing.T, body []byte) (flags []byte, frames [][]byte) {
+	r := bytes.NewReader(body)
+	for {
+		head := make([]byte, 5)
+		if _, err := io.ReadFull(r, head); err == io.EOF {
+			return
+		} else if !assert.NoError(t, err) {
+			return
+		}
+		data := make([]byte, binary.BigEndian.Uint32(head[1:]))
+		_, err := io.ReadFull(r, data)
+		assert.NoError(t, err)
+		flags = append(flags, head[0])
+		frames = append(frames, data)
+	}
+}
+
+func rpcDo(m http.Handler, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+	req.Header.Set(Header_content_type, contentType)
+	rw := httptest.NewRecorder()
+	m.ServeHTTP(rw, req)
+	return rw
+}
+
+func TestWithRPCRoutes(t *testing.T) {
+	routes := withRPCRoutes(new(rpcGreeter).URLPatterns())
+	assert.Len(t, routes, 5)
+	assert.Equal(t, Route{Method: http.MethodPost, Path: "/gwtest.Greeter/Hello", ResourceFuncName: "Hello",
+		Metadata: map[string]string{RPC_METHOD_METADATA: "/gwtest.Greeter/Hello"}}, routes[3])
+	assert.True(t, isRPCRoute(routes[4]))
+	assert.False(t, isRPCRoute(routes[0]))
+	assert.Len(t, withRPCRoutes(new(muxGreeter).URLPatterns()), 3)
+}
+
+func TestRPCRoutesNotListed(t *testing.T) {
+	initLogger()
+	initArchaius()
+	m, err := NewMux(&rpcGreeter{})
+	assert.NoError(t, err)
+	assert.Len(t, m.ListRoutes(), 3)
+	for _, info := range m.ListRoutes() {
+		assert.False(t, isRPCRoute(info.Route), info.Route.Path)
+	}
+
+	r := newSwapServer(t)
+	_, err = r.Register(&rpcGreeter{})
+	assert.NoError(t, err)
+	assert.Len(t, r.ListRoutes(), 3)
+	// rpc路由仍然注册
+	assert.Len(t, r.ws[0].Routes(), 2)
+}
+
+func TestGrpcWeb(t *testing.T) {
+	initLogger()
+	m, err := NewMux(&rpcGreeter{})
+	assert.NoError(t, err)
+
+	data, _ := proto.Marshal(&wrapperspb.StringValue{Value: "world"})
+	rw := rpcDo(m, "/gwtest.Greeter/Hello", "application/grpc-web+proto", rpcFrame(0, data))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/grpc-web+proto", rw.Header().Get(Header_content_type))
+	flags, frames := readRPCFrames(t, rw.Body.Bytes())
+	assert.Equal(t, []byte{0, grpcWebFrameTrailer}, flags)
+	var reply wrapperspb.StringValue
+	assert.NoError(t, proto.Unmarshal(frames[0], &reply))
+	assert.Equal(t, "hello world", reply.GetValue())
+	assert.Equal(t, "grpc-status: 0\r\ngrpc-message: \r\n", string(frames[1]))
+
+	// 错误以trailer返回, 错误解析与http路由相同
+	rw = rpcDo(m, "/gwtest.Greeter/Hello", "application/grpc-web+json", rpcFrame(0, []byte(`""`)))
+	flags, frames = readRPCFrames(t, rw.Body.Bytes())
+	assert.Equal(t, []byte{grpcWebFrameTrailer}, flags)
+	assert.Equal(t, "grpc-status: 5\r\ngrpc-message: (10001)not found\r\n", string(frames[0]))
+
+	rw = rpcDo(m, "/gwtest.Greeter/Hello", "application/grpc-web", []byte{0, 0, 0})
+	_, frames = readRPCFrames(t, rw.Body.Bytes())
+	assert.Contains(t, string(frames[0]), "grpc-status: 3\r\n")
+	assert.Contains(t, string(frames[0]), "10419")
+
+	rw = rpcDo(m, "/gwtest.Greeter/Hello", "text/plain", nil)
+	assert.Equal(t, http.StatusUnsupportedMediaType, rw.Code)
+}
+
+func TestConnect(t *testing.T) {
+	initLogger()
+	m, err := NewMux(&rpcGreeter{})
+	assert.NoError(t, err)
+
+	rw := rpcDo(m, "/gwtest.Greeter/Hello", "application/json", []byte(`"world"`))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get(Header_content_type))
+	assert.JSONEq(t, `"hello world"`, rw.Body.String())
+
+	rw = rpcDo(m, "/gwtest.Greeter/Hello", "application/json", []byte(`""`))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+	var cerr connectError
+	assert.NoError(t, json.Unmarshal(rw.Body.Bytes(), &cerr))
+	assert.Equal(t, connectError{Code: "not_found", Message: "(10001)not found"}, cerr)
+
+	// 服务端流式响应
+	rw = rpcDo(m, "/gwtest.Greeter/Export", "application/connect+proto", rpcFrame(0, nil))
+	assert.Equal(t, http.StatusOK, rw.Code)
+	flags, frames := readRPCFrames(t, rw.Body.Bytes())
+	assert.Equal(t, []byte{0, 0, connectFrameEnd}, flags)
+	var body httpbody.HttpBody
+	assert.NoError(t, proto.Unmarshal(frames[1], &body))
+	assert.Equal(t, "b", string(body.GetData()))
+	assert.Equal(t, "{}", string(frames[2]))
+
+	rw = rpcDo(m, "/gwtest.Greeter/Hello", "application/connect+json", rpcFrame(0, []byte(`""`)))
+	flags, frames = readRPCFrames(t, rw.Body.Bytes())
+	assert.Equal(t, []byte{connectFrameEnd}, flags)
+	assert.JSONEq(t, `{"error":{"code":"not_found","message":"(10001)not found"}}`, string(frames[0]))
+}
+
+func TestEncodeGrpcMessage(t *testing.T) {
+	assert.Equal(t, "(10001)not found", encodeGrpcMessage("(10001)not found"))
+	assert.Equal(t, "100%25%0A%E4%B8%AD", encodeGrpcMessage("100%\n中"))
+	assert.False(t, strings.Contains(encodeGrpcMessage("a\r\nb"), "\n"))
+}
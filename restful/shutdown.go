@@ -0,0 +1,212 @@
+package restful
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chassis/go-archaius"
+	"github.com/go-chassis/go-chassis/core/registry"
+	"github.com/go-chassis/go-chassis/pkg/metrics"
+	"github.com/go-chassis/go-chassis/pkg/runtime"
+	"github.com/go-mesh/openlogging"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// 优雅停止配置
+const (
+	ShutdownConfigPrefix    = "cse.restful.shutdown"
+	ShutdownPreStopDelayKey = ShutdownConfigPrefix + ".preStopDelay" // 标记未就绪后等待负载均衡摘除实例的时间, 如 5s
+	ShutdownDrainTimeoutKey = ShutdownConfigPrefix + ".drainTimeout" // 等待进行中的请求结束的最长时间, 超时后强制关闭
+)
+
+// DefaultShutdownDrainTimeout 默认的排空超时时间
+var DefaultShutdownDrainTimeout = 30 * time.Second
+
+// 停止结果
+const (
+	shutdownGraceful = "graceful"
+	shutdownForced   = "forced"
+	shutdownError    = "error"
+)
+
+var (
+	shutdownTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "restful_shutdowns_total",
+		Help: "Total number of server shutdowns by result.",
+	}, []string{"result"})
+	shutdownDrainSeconds = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "restful_shutdown_drain_seconds",
+		Help: "Time spent draining in-flight requests in the last shutdown.",
+	})
+	shutdownAbandoned = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "restful_shutdown_abandoned_requests",
+		Help: "Number of requests force closed in the last shutdown.",
+	})
+)
+
+func init() {
+	metrics.GetSystemPrometheusRegistry().MustRegister(shutdownTotal, shutdownDrainSeconds, shutdownAbandoned)
+}
+
+// ShutdownOptions 优雅停止的参数
+type ShutdownOptions struct {
+	PreStopDelay time.Duration // 标记未就绪后开始排空前的等待时间
+	DrainTimeout time.Duration // 排空的最长时间, 超时后强制关闭连接
+}
+
+// loadShutdownOptions 从配置中加载优雅停止的参数
+func loadShutdownOptions() ShutdownOptions {
+	opts := ShutdownOptions{DrainTimeout: DefaultShutdownDrainTimeout}
+	for key, d := range map[string]*time.Duration{
+		ShutdownPreStopDelayKey: &opts.PreStopDelay,
+		ShutdownDrainTimeoutKey: &opts.DrainTimeout,
+	} {
+		v := archaius.GetString(key, "")
+		if v == "" {
+			continue
+		}
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed < 0 {
+			openlogging.GetLogger().Warnf("invalid shutdown config %s '%s'", key, v)
+			continue
+		}
+		*d = parsed
+	}
+	return opts
+}
+
+// lifecycle 服务的就绪状态及进行中的请求数
+type lifecycle struct {
+	ready    int32
+	inflight int64
+	draining chan struct{}
+	stopOnce sync.Once
+	stopErr  error
+}
+
+func newLifecycle() *lifecycle {
+	return &lifecycle{draining: make(chan struct{})}
+}
+
+// track 记录进行中的请求, 返回请求结束时调用的函数
+// 包括被劫持的连接, 如WebSocket桥接, 在处理函数返回前都计为进行中
+func (l *lifecycle) track() func() {
+	if l == nil {
+		return func() {}
+	}
+	atomic.AddInt64(&l.inflight, 1)
+	return func() {
+		atomic.AddInt64(&l.inflight, -1)
+	}
+}
+
+// wait 等待进行中的请求结束, 超时返回上下文的错误
+func (l *lifecycle) wait(ctx context.Context) error {
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for atomic.LoadInt64(&l.inflight) > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
+
+// Ready 服务是否就绪, 启动后就绪, 停止时首先标记为未就绪
+func (r *RestfulServer) Ready() bool {
+	return atomic.LoadInt32(&r.lifecycle.ready) == 1
+}
+
+// Draining 开始排空时关闭, 流式响应及WebSocket桥接可据此主动结束
+func (r *RestfulServer) Draining() <-chan struct{} {
+	return r.lifecycle.draining
+}
+
+// SetShutdownOptions 设置优雅停止的参数
+func (r *RestfulServer) SetShutdownOptions(opts ShutdownOptions) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.shutdown = opts
+}
+
+// Stop 优雅停止restful服务
+// 标记未就绪并从注册中心注销实例, 等待PreStopDelay后排空进行中的请求, 超过DrainTimeout时强制关闭
+func (r *RestfulServer) Stop() error {
+	if r.server == nil {
+		openlogging.Info("http server never started")
+		return nil
+	}
+	r.lifecycle.stopOnce.Do(func() {
+		r.lifecycle.stopErr = r.stop()
+	})
+	return r.lifecycle.stopErr
+}
+
+// deregisterInstance 通过注册中心注销本实例, 使消费者在排空前不再路由到本实例
+func deregisterInstance() {
+	if registry.DefaultRegistrator == nil || runtime.InstanceID == "" {
+		return
+	}
+	if err := registry.DefaultRegistrator.UnRegisterMicroServiceInstance(runtime.ServiceID, runtime.InstanceID); err != nil {
+		openlogging.GetLogger().Warnf("unregister instance %s failed: %s", runtime.InstanceID, err)
+		return
+	}
+	openlogging.GetLogger().Infof("instance %s unregistered", runtime.InstanceID)
+}
+
+func (r *RestfulServer) stop() error {
+	r.mux.RLock()
+	opts := r.shutdown
+	r.mux.RUnlock()
+
+	atomic.StoreInt32(&r.lifecycle.ready, 0)
+	deregisterInstance()
+	if opts.PreStopDelay > 0 {
+		openlogging.GetLogger().Infof("http server not ready, wait %s before draining", opts.PreStopDelay)
+		time.Sleep(opts.PreStopDelay)
+	}
+
+	start := time.Now()
+	close(r.lifecycle.draining)
+	openlogging.GetLogger().Infof("http server draining %d in-flight requests, timeout %s",
+		atomic.LoadInt64(&r.lifecycle.inflight), opts.DrainTimeout)
+	ctx := context.Background()
+	if opts.DrainTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.DrainTimeout)
+		defer cancel()
+	}
+	err := r.server.Shutdown(ctx)
+	if err == nil {
+		// Shutdown不等待被劫持的连接
+		err = r.lifecycle.wait(ctx)
+	}
+	shutdownDrainSeconds.Set(time.Since(start).Seconds())
+
+	switch err {
+	case nil:
+		shutdownTotal.WithLabelValues(shutdownGraceful).Inc()
+		shutdownAbandoned.Set(0)
+		openlogging.GetLogger().Infof("http server stopped gracefully in %s", time.Since(start))
+		return nil
+	case context.DeadlineExceeded:
+		abandoned := atomic.LoadInt64(&r.lifecycle.inflight)
+		shutdownTotal.WithLabelValues(shutdownForced).Inc()
+		shutdownAbandoned.Set(float64(abandoned))
+		openlogging.GetLogger().Warnf("http server drain timeout after %s, force close %d requests", opts.DrainTimeout, abandoned)
+		if closeErr := r.server.Close(); closeErr != nil && closeErr != http.ErrServerClosed {
+			openlogging.Warn("http close error: " + closeErr.Error())
+		}
+		return fmt.Errorf("http server drain timeout, %d requests force closed", abandoned)
+	default:
+		shutdownTotal.WithLabelValues(shutdownError).Inc()
+		openlogging.Warn("http shutdown error: " + err.Error())
+		return err
+	}
+}
@@ -0,0 +1,128 @@
+package restful
+
+import (
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/go-chassis/go-chassis/core/registry"
+	"github.com/go-chassis/go-chassis/core/registry/mock"
+	"github.com/go-chassis/go-chassis/core/server"
+	"github.com/go-chassis/go-chassis/pkg/runtime"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// unregisterRecorder 记录注销的实例
+type unregisterRecorder struct {
+	mock.RegistratorMock
+	unregistered []string
+}
+
+func (m *unregisterRecorder) UnRegisterMicroServiceInstance(microServiceID, microServiceInstanceID string) error {
+	m.unregistered = append(m.unregistered, microServiceID+"/"+microServiceInstanceID)
+	return nil
+}
+
+// newShutdownServer 启动只包含指定路由的服务, 返回服务地址
+func newShutdownServer(t *testing.T, opts ShutdownOptions, entries ...routeEntry) (*RestfulServer, string) {
+	initLogger()
+	r := &RestfulServer{
+		opts:      server.Options{ProtocolServerName: "rest-shutdown-test"},
+		routes:    newRouteTable(),
+		lifecycle: newLifecycle(),
+		shutdown:  opts,
+	}
+	assert.NoError(t, r.apply(entries))
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	r.server = &http.Server{Handler: r}
+	go r.server.Serve(l)
+	atomic.StoreInt32(&r.lifecycle.ready, 1)
+	return r, "http://" + l.Addr().String()
+}
+
+func TestStopGraceful(t *testing.T) {
+	started := make(chan struct{})
+	slow := func(req *restful.Request, rep *restful.Response) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		rep.WriteHeader(http.StatusOK)
+	}
+	registrator, serviceID, instanceID := registry.DefaultRegistrator, runtime.ServiceID, runtime.InstanceID
+	defer func() {
+		registry.DefaultRegistrator, runtime.ServiceID, runtime.InstanceID = registrator, serviceID, instanceID
+	}()
+	recorder := &unregisterRecorder{}
+	registry.DefaultRegistrator, runtime.ServiceID, runtime.InstanceID = recorder, "svc", "ins"
+	r, addr := newShutdownServer(t, ShutdownOptions{PreStopDelay: 20 * time.Millisecond, DrainTimeout: time.Second},
+		routeEntry{route: Route{Method: http.MethodGet, Path: "/slow"}, handler: slow})
+	assert.True(t, r.Ready())
+
+	done := make(chan int)
+	go func() {
+		resp, err := http.Get(addr + "/slow")
+		if err != nil {
+			done <- 0
+			return
+		}
+		resp.Body.Close()
+		done <- resp.StatusCode
+	}()
+	<-started
+	graceful := testutil.ToFloat64(shutdownTotal.WithLabelValues(shutdownGraceful))
+	assert.NoError(t, r.Stop())
+	assert.Equal(t, http.StatusOK, <-done)
+	assert.False(t, r.Ready())
+	assert.Equal(t, []string{"svc/ins"}, recorder.unregistered)
+	assert.Equal(t, graceful+1, testutil.ToFloat64(shutdownTotal.WithLabelValues(shutdownGraceful)))
+	assert.NoError(t, r.Stop())
+}
+
+func TestStopHijacked(t *testing.T) {
+	var r *RestfulServer
+	started := make(chan struct{})
+	bridge := func(req *restful.Request, rep *restful.Response) {
+		conn, _, err := rep.ResponseWriter.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		close(started)
+		// 桥接在排空时主动结束
+		<-r.Draining()
+		time.Sleep(50 * time.Millisecond)
+	}
+	r, addr := newShutdownServer(t, ShutdownOptions{DrainTimeout: time.Second},
+		routeEntry{route: Route{Method: http.MethodGet, Path: "/ws"}, handler: bridge})
+	go http.Get(addr + "/ws")
+	<-started
+	start := time.Now()
+	assert.NoError(t, r.Stop())
+	assert.True(t, time.Since(start) >= 50*time.Millisecond)
+	assert.Equal(t, int64(0), atomic.LoadInt64(&r.lifecycle.inflight))
+}
+
+func TestStopForced(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	defer close(release)
+	stuck := func(req *restful.Request, rep *restful.Response) {
+		close(started)
+		select {
+		case <-req.Request.Context().Done():
+		case <-release:
+		}
+	}
+	r, addr := newShutdownServer(t, ShutdownOptions{DrainTimeout: 50 * time.Millisecond},
+		routeEntry{route: Route{Method: http.MethodGet, Path: "/stuck"}, handler: stuck})
+	go http.Get(addr + "/stuck")
+	<-started
+	forced := testutil.ToFloat64(shutdownTotal.WithLabelValues(shutdownForced))
+	assert.Error(t, r.Stop())
+	assert.Equal(t, forced+1, testutil.ToFloat64(shutdownTotal.WithLabelValues(shutdownForced)))
+	assert.Equal(t, float64(1), testutil.ToFloat64(shutdownAbandoned))
+}
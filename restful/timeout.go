@@ -0,0 +1,109 @@
+package restful
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-mesh/openlogging"
+)
+
+// grpc-timeout头域的单位
+var grpcTimeoutUnits = map[byte]time.Duration{
+	'H': time.Hour,
+	'M': time.Minute,
+	'S': time.Second,
+	'm': time.Millisecond,
+	'u': time.Microsecond,
+	'n': time.Nanosecond,
+}
+
+// parseGrpcTimeout 解析grpc-timeout头域
+// 格式为不超过8位的正整数加单位, 例如: 100m, 5S
+func parseGrpcTimeout(v string) (time.Duration, error) {
+	if len(v) < 2 || len(v) > 9 {
+		return 0, fmt.Errorf("invalid grpc-timeout '%s'", v)
+	}
+	unit, ok := grpcTimeoutUnits[v[len(v)-1]]
+	if !ok {
+		return 0, fmt.Errorf("invalid grpc-timeout unit in '%s'", v)
+	}
+	n, err := strconv.ParseInt(v[:len(v)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid grpc-timeout '%s'", v)
+	}
+	return time.Duration(n) * unit, nil
+}
+
+// parseRequestTimeout 解析X-Request-Timeout头域
+// 支持go的时间格式(如 1.5s, 200ms), 纯数字则单位为秒
+func parseRequestTimeout(v string) (time.Duration, error) {
+	if n, err := strconv.ParseFloat(v, 64); err == nil {
+		if n < 0 {
+			return 0, fmt.Errorf("invalid x-request-timeout '%s'", v)
+		}
+		return time.Duration(n * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid x-request-timeout '%s'", v)
+	}
+	return d, nil
+}
+
+// parseConnectTimeout 解析Connect-Timeout-Ms头域, 为不超过10位的毫秒数
+func parseConnectTimeout(v string) (time.Duration, error) {
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil || n < 0 || len(v) > 10 {
+		return 0, fmt.Errorf("invalid connect-timeout-ms '%s'", v)
+	}
+	return time.Duration(n) * time.Millisecond, nil
+}
+
+// routeTimeout 从路由元数据中获取默认超时时间, 未设置时返回0
+func routeTimeout(route Route) (time.Duration, error) {
+	v := strings.TrimSpace(route.Metadata[TIMEOUT_METADATA])
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid timeout '%s' in route '%s'", v, route.ResourceFuncName)
+	}
+	return d, nil
+}
+
+// requestTimeout 获取请求的超时时间
+// 优先使用grpc-timeout及Connect-Timeout-Ms头域, 其次为X-Request-Timeout头域, 并且不超过路由默认超时时间
+// 返回0表示没有超时限制
+func requestTimeout(req *http.Request, max time.Duration) time.Duration {
+	var timeout time.Duration
+	var err error
+	if v := req.Header.Get(Header_grpc_timeout); v != "" {
+		timeout, err = parseGrpcTimeout(v)
+	} else if v := req.Header.Get(Header_connect_timeout); v != "" {
+		timeout, err = parseConnectTimeout(v)
+	} else if v := req.Header.Get(Header_x_request_timeout); v != "" {
+		timeout, err = parseRequestTimeout(v)
+	}
+	if err != nil {
+		openlogging.GetLogger().Warnf("ignore request timeout: %s", err.Error())
+		timeout = 0
+	}
+	if max > 0 && (timeout <= 0 || timeout > max) {
+		timeout = max
+	}
+	return timeout
+}
+
+// newRequestContext 为请求创建带超时的上下文
+// parent需派生自请求的上下文, 客户端断开连接时上下文同时被取消
+func newRequestContext(parent context.Context, req *http.Request, max time.Duration) (context.Context, context.CancelFunc) {
+	if timeout := requestTimeout(req, max); timeout > 0 {
+		return context.WithTimeout(parent, timeout)
+	}
+	return context.WithCancel(parent)
+}
@@ -0,0 +1,105 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestParseGrpcTimeout(t *testing.T) {
+	tests := []struct {
+		in   string
+		want time.Duration
+		err  bool
+	}{
+		{"100m", 100 * time.Millisecond, false},
+		{"5S", 5 * time.Second, false},
+		{"1H", time.Hour, false},
+		{"10", 0, true},
+		{"S", 0, true},
+		{"123456789S", 0, true},
+		{"-1S", 0, true},
+	}
+	for _, tc := range tests {
+		got, err := parseGrpcTimeout(tc.in)
+		if tc.err {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestRequestTimeout(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	assert.Equal(t, time.Duration(0), requestTimeout(req, 0))
+	assert.Equal(t, time.Second, requestTimeout(req, time.Second))
+
+	req.Header.Set(Header_x_request_timeout, "1.5")
+	assert.Equal(t, 1500*time.Millisecond, requestTimeout(req, 0))
+	assert.Equal(t, time.Second, requestTimeout(req, time.Second))
+
+	req.Header.Set(Header_grpc_timeout, "200m")
+	assert.Equal(t, 200*time.Millisecond, requestTimeout(req, time.Second))
+
+	req.Header.Set(Header_grpc_timeout, "bad")
+	assert.Equal(t, time.Second, requestTimeout(req, time.Second))
+
+	req.Header.Del(Header_grpc_timeout)
+	req.Header.Set(Header_connect_timeout, "300")
+	assert.Equal(t, 300*time.Millisecond, requestTimeout(req, time.Second))
+}
+
+func TestRouteTimeout(t *testing.T) {
+	d, err := routeTimeout(Route{})
+	assert.NoError(t, err)
+	assert.Equal(t, time.Duration(0), d)
+
+	d, err = routeTimeout(Route{Metadata: map[string]string{TIMEOUT_METADATA: "3s"}})
+	assert.NoError(t, err)
+	assert.Equal(t, 3*time.Second, d)
+
+	_, err = routeTimeout(Route{Metadata: map[string]string{TIMEOUT_METADATA: "3"}})
+	assert.Error(t, err)
+}
+
+func TestNewRequestContext(t *testing.T) {
+	parent, disconnect := context.WithCancel(context.Background())
+	req, _ := http.NewRequest(http.MethodGet, "/", nil)
+	req = req.WithContext(parent)
+	req.Header.Set(Header_grpc_timeout, "1H")
+
+	ctx, cancel := newRequestContext(req.Context(), req, time.Minute)
+	defer cancel()
+	deadline, ok := ctx.Deadline()
+	assert.True(t, ok)
+	assert.True(t, time.Until(deadline) <= time.Minute)
+
+	disconnect()
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("context not canceled after client disconnect")
+	}
+}
+
+func TestParseErrorDeadline(t *testing.T) {
+	code, errCode, err := ParseError(context.DeadlineExceeded)
+	assert.Equal(t, codes.DeadlineExceeded, code)
+	assert.Equal(t, TIMEOUT_ERR, errCode)
+	assert.Error(t, err)
+
+	code, errCode, _ = ParseError(status.Error(codes.DeadlineExceeded, "slow"))
+	assert.Equal(t, codes.DeadlineExceeded, code)
+	assert.Equal(t, TIMEOUT_ERR, errCode)
+
+	code, errCode, _ = ParseError(status.Error(codes.DeadlineExceeded, "(20001)slow"))
+	assert.Equal(t, codes.DeadlineExceeded, code)
+	assert.Equal(t, 20001, errCode)
+}
@@ -0,0 +1,74 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/contrib/propagators/b3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+)
+
+const tracerName = "github.com/wksw/protoc-gen-restful2grpc/restful"
+
+// TracePropagator 从请求头中提取链路信息
+// 默认支持W3C traceparent以及B3单头域和多头域格式, 可替换为其他实现
+var TracePropagator propagation.TextMapPropagator = propagation.NewCompositeTextMapPropagator(
+	propagation.TraceContext{},
+	propagation.Baggage{},
+	b3.New(),
+)
+
+// startServerSpan 为路由请求创建服务端span, span名称为路由对应的函数名
+// 链路由otel全局TracerProvider导出
+func startServerSpan(req *http.Request, route Route, schemaName string) (context.Context, trace.Span) {
+	ctx := TracePropagator.Extract(req.Context(), propagation.HeaderCarrier(req.Header))
+	return otel.Tracer(tracerName).Start(ctx, route.ResourceFuncName,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.method", req.Method),
+			attribute.String("http.route", routeTemplate(route)),
+			attribute.String("http.target", req.URL.RequestURI()),
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", schemaName),
+			attribute.String("rpc.method", route.ResourceFuncName),
+		))
+}
+
+// endServerSpan 记录http状态码并结束span
+func endServerSpan(span trace.Span, httpStatus int) {
+	span.SetAttributes(attribute.Int("http.status_code", httpStatus))
+	if httpStatus >= http.StatusInternalServerError {
+		span.SetStatus(otelcodes.Error, http.StatusText(httpStatus))
+	}
+	span.End()
+}
+
+// traceResponse 在当前span中记录grpc状态码及错误码
+func traceResponse(ctx context.Context, code codes.Code, errCode int) {
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return
+	}
+	span.SetAttributes(
+		attribute.Int("rpc.grpc.status_code", int(code)),
+		attribute.String("err_code", strconv.Itoa(errCode)),
+	)
+}
+
+// requestId 获取请求ID
+// 优先使用paasport-trace-id, 不存在时使用当前链路的trace id
+func requestId(b *Context) string {
+	if id := b.ReadResponseWriter().Header().Get(Header_trace); id != "" {
+		return id
+	}
+	if sc := trace.SpanContextFromContext(b.Ctx); sc.HasTraceID() {
+		return sc.TraceID().String()
+	}
+	return ""
+}
@@ -0,0 +1,102 @@
+package restful
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc/codes"
+)
+
+// newTestTracer 安装内存导出的TracerProvider, 返回恢复原有TracerProvider及传播器的函数
+func newTestTracer() (*tracetest.InMemoryExporter, func()) {
+	provider, propagator := otel.GetTracerProvider(), TracePropagator
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return exporter, func() {
+		otel.SetTracerProvider(provider)
+		TracePropagator = propagator
+	}
+}
+
+func spanAttribute(span tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestServerSpanPropagation(t *testing.T) {
+	exporter, restore := newTestTracer()
+	defer restore()
+	route := Route{Method: http.MethodGet, Path: "/hello/{name}", Version: "v1", ResourceFuncName: "Hello"}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		traceID string
+	}{
+		{"traceparent", map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		}, "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"b3 single", map[string]string{
+			"b3": "80f198ee56343ba864fe8b2a57d3eff7-e457b5a2e4d86bd1-1",
+		}, "80f198ee56343ba864fe8b2a57d3eff7"},
+		{"b3 multi", map[string]string{
+			"x-b3-traceid": "463ac35c9f6413ad48485a3953bb6124",
+			"x-b3-spanid":  "0020000000000001",
+			"x-b3-sampled": "1",
+		}, "463ac35c9f6413ad48485a3953bb6124"},
+	}
+	for _, tc := range tests {
+		exporter.Reset()
+		req, _ := http.NewRequest(http.MethodGet, "/v1/hello/world", nil)
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		ctx, span := startServerSpan(req, route, "GreeterHttpHandler")
+		traceResponse(ctx, codes.NotFound, 10001)
+		endServerSpan(span, http.StatusNotFound)
+
+		spans := exporter.GetSpans()
+		if !assert.Len(t, spans, 1, tc.name) {
+			continue
+		}
+		assert.Equal(t, "Hello", spans[0].Name, tc.name)
+		assert.Equal(t, tc.traceID, spans[0].SpanContext.TraceID().String(), tc.name)
+		assert.True(t, spans[0].Parent.IsRemote(), tc.name)
+		assert.Equal(t, "/v1/hello/{name}", spanAttribute(spans[0], "http.route").AsString(), tc.name)
+		assert.Equal(t, int64(http.StatusNotFound), spanAttribute(spans[0], "http.status_code").AsInt64(), tc.name)
+		assert.Equal(t, int64(codes.NotFound), spanAttribute(spans[0], "rpc.grpc.status_code").AsInt64(), tc.name)
+		assert.Equal(t, "10001", spanAttribute(spans[0], "err_code").AsString(), tc.name)
+	}
+}
+
+func TestRequestIdFromTrace(t *testing.T) {
+	_, restore := newTestTracer()
+	defer restore()
+	req, _ := http.NewRequest(http.MethodGet, "/hello", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx, span := startServerSpan(req, Route{ResourceFuncName: "Hello"}, "GreeterHttpHandler")
+	defer span.End()
+
+	b := NewBaseServer(ctx)
+	b.Resp = restful.NewResponse(httptest.NewRecorder())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestId(b))
+
+	b.Resp.Header().Set(Header_trace, "paasport")
+	assert.Equal(t, "paasport", requestId(b))
+
+	b = NewBaseServer(context.Background())
+	b.Resp = restful.NewResponse(httptest.NewRecorder())
+	assert.Equal(t, "", requestId(b))
+}
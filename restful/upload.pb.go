@@ -0,0 +1,225 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.24.0
+// 	protoc        v3.12.4
+// source: github.com/wksw/protoc-gen-restful2grpc/restful/upload.proto
+
+package restful
+
+import (
+	proto "github.com/golang/protobuf/proto"
+	descriptor "github.com/golang/protobuf/protoc-gen-go/descriptor"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// This is a compile-time assertion that a sufficiently up-to-date version
+// of the legacy proto package is being used.
+const _ = proto.ProtoPackageIsVersion4
+
+// Upload multipart/form-data上传的文件
+type Upload struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 文件名
+	Filename string `protobuf:"bytes,1,opt,name=filename,proto3" json:"filename,omitempty"`
+	// 文件类型
+	ContentType string `protobuf:"bytes,2,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	// 文件大小
+	Size int64 `protobuf:"varint,3,opt,name=size,proto3" json:"size,omitempty"`
+	// 文件内容, 路由未开启upload_temp时写入
+	Data []byte `protobuf:"bytes,4,opt,name=data,proto3" json:"data,omitempty"`
+	// 临时文件路径, 路由开启upload_temp时写入, 请求结束后删除
+	Path string `protobuf:"bytes,5,opt,name=path,proto3" json:"path,omitempty"`
+}
+
+func (x *Upload) Reset() {
+	*x = Upload{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Upload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Upload) ProtoMessage() {}
+
+func (x *Upload) ProtoReflect() protoreflect.Message {
+	mi := &file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Upload.ProtoReflect.Descriptor instead.
+func (*Upload) Descriptor() ([]byte, []int) {
+	return file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Upload) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *Upload) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *Upload) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *Upload) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+func (x *Upload) GetPath() string {
+	if x != nil {
+		return x.Path
+	}
+	return ""
+}
+
+var file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_extTypes = []protoimpl.ExtensionInfo{
+	{
+		ExtendedType:  (*descriptor.FieldOptions)(nil),
+		ExtensionType: (*bool)(nil),
+		Field:         50401,
+		Name:          "restful2grpc.upload",
+		Tag:           "varint,50401,opt,name=upload",
+		Filename:      "github.com/wksw/protoc-gen-restful2grpc/restful/upload.proto",
+	},
+}
+
+// Extension fields to descriptor.FieldOptions.
+var (
+	// 标记bytes或Upload类型的字段接收multipart/form-data上传的文件
+	//
+	// optional bool upload = 50401;
+	E_Upload = &file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_extTypes[0]
+)
+
+var File_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto protoreflect.FileDescriptor
+
+var file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDesc = []byte{
+	0x0a, 0x3c, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x77, 0x6b, 0x73,
+	0x77, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x2d, 0x72, 0x65, 0x73,
+	0x74, 0x66, 0x75, 0x6c, 0x32, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x72, 0x65, 0x73, 0x74, 0x66, 0x75,
+	0x6c, 0x2f, 0x75, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x0c,
+	0x72, 0x65, 0x73, 0x74, 0x66, 0x75, 0x6c, 0x32, 0x67, 0x72, 0x70, 0x63, 0x1a, 0x20, 0x67, 0x6f,
+	0x6f, 0x67, 0x6c, 0x65, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x64, 0x65,
+	0x73, 0x63, 0x72, 0x69, 0x70, 0x74, 0x6f, 0x72, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x83,
+	0x01, 0x0a, 0x06, 0x55, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c,
+	0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c,
+	0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x63, 0x6f, 0x6e,
+	0x74, 0x65, 0x6e, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x12, 0x0a, 0x04, 0x73, 0x69, 0x7a, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x73, 0x69, 0x7a, 0x65, 0x12, 0x12, 0x0a, 0x04,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x04, 0x64, 0x61, 0x74, 0x61,
+	0x12, 0x12, 0x0a, 0x04, 0x70, 0x61, 0x74, 0x68, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x70, 0x61, 0x74, 0x68, 0x3a, 0x37, 0x0a, 0x06, 0x75, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x12, 0x1d,
+	0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66,
+	0x2e, 0x46, 0x69, 0x65, 0x6c, 0x64, 0x4f, 0x70, 0x74, 0x69, 0x6f, 0x6e, 0x73, 0x18, 0xe1, 0x89,
+	0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x75, 0x70, 0x6c, 0x6f, 0x61, 0x64, 0x42, 0x31, 0x5a,
+	0x2f, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e, 0x63, 0x6f, 0x6d, 0x2f, 0x77, 0x6b, 0x73, 0x77,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x2d, 0x67, 0x65, 0x6e, 0x2d, 0x72, 0x65, 0x73, 0x74,
+	0x66, 0x75, 0x6c, 0x32, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x72, 0x65, 0x73, 0x74, 0x66, 0x75, 0x6c,
+	0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescOnce sync.Once
+	file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescData = file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDesc
+)
+
+func file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescGZIP() []byte {
+	file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescOnce.Do(func() {
+		file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescData = protoimpl.X.CompressGZIP(file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescData)
+	})
+	return file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDescData
+}
+
+var file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_goTypes = []interface{}{
+	(*Upload)(nil),                  // 0: restful2grpc.Upload
+	(*descriptor.FieldOptions)(nil), // 1: google.protobuf.FieldOptions
+}
+var file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_depIdxs = []int32{
+	1, // 0: restful2grpc.upload:extendee -> google.protobuf.FieldOptions
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	0, // [0:1] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_init() }
+func file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_init() {
+	if File_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Upload); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 1,
+			NumServices:   0,
+		},
+		GoTypes:           file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_goTypes,
+		DependencyIndexes: file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_depIdxs,
+		MessageInfos:      file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_msgTypes,
+		ExtensionInfos:    file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_extTypes,
+	}.Build()
+	File_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto = out.File
+	file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_rawDesc = nil
+	file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_goTypes = nil
+	file_github_com_wksw_protoc_gen_restful2grpc_restful_upload_proto_depIdxs = nil
+}